@@ -0,0 +1,211 @@
+package ldif
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	cacheContentExt  = ".data"
+	cacheMetadataExt = ".json"
+)
+
+// Cache keeps the last synced content of the sources in the directory, so the
+// content is downloaded only when it was modified
+type Cache struct {
+	dir string
+}
+
+// SyncResult describes the cached content after the sync
+type SyncResult struct {
+	// Path is the file with the cached content
+	Path string `json:"-"`
+	// Name is the name of the source content, see NamedSource
+	Name string `json:"name,omitempty"`
+	// Hash is hex-encoded SHA-256 of the content
+	Hash       string     `json:"hash"`
+	Validators Validators `json:"validators"`
+	// UpdatedAt is the time when the cached content was updated last time
+	UpdatedAt time.Time `json:"updated_at"`
+	// Unchanged is set when the content is the same as on the previous sync, so
+	// there is no need to parse it again
+	Unchanged bool `json:"-"`
+}
+
+// NewCache creates new cache in the directory, the directory is created when
+// it does not exist
+func NewCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating cache directory %s: %w", dir, err)
+	}
+
+	return &Cache{dir: dir}, nil
+}
+
+// Sync downloads the content of the source into the cache under the key. When
+// the source is a ConditionalSource, the content is requested only if it was
+// modified since the previous sync. Otherwise, the content is downloaded and
+// compared with the cached one by hash.
+func (c *Cache) Sync(ctx context.Context, key string, src Source) (SyncResult, error) {
+	if err := validateCacheKey(key); err != nil {
+		return SyncResult{}, err
+	}
+
+	previous, err := c.Result(key)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return SyncResult{}, err
+	}
+	cached := err == nil
+
+	var (
+		content    io.ReadCloser
+		validators Validators
+	)
+	if conditional, ok := src.(ConditionalSource); ok {
+		var known Validators
+		if cached {
+			known = previous.Validators
+		}
+
+		content, validators, err = conditional.OpenIfModified(ctx, known)
+		if errors.Is(err, ErrNotModified) && cached {
+			previous.Unchanged = true
+			return previous, nil
+		}
+	} else {
+		content, err = src.Open(ctx)
+	}
+	if err != nil {
+		return SyncResult{}, fmt.Errorf("opening source: %w", err)
+	}
+	defer content.Close()
+
+	hash, tmpPath, err := c.download(key, content)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	defer os.Remove(tmpPath)
+
+	result := SyncResult{
+		Path:       c.path(key, cacheContentExt),
+		Name:       sourceName(src),
+		Hash:       hash,
+		Validators: validators,
+		UpdatedAt:  time.Now().UTC(),
+	}
+	if cached && previous.Hash == hash {
+		result.UpdatedAt = previous.UpdatedAt
+		result.Unchanged = true
+	} else if err = os.Rename(tmpPath, result.Path); err != nil {
+		return SyncResult{}, fmt.Errorf("saving cached content: %w", err)
+	}
+
+	if err = c.saveResult(key, result); err != nil {
+		return SyncResult{}, err
+	}
+
+	return result, nil
+}
+
+// Result returns the result of the last sync under the key, it fails with
+// os.ErrNotExist when there is no cached content
+func (c *Cache) Result(key string) (SyncResult, error) {
+	if err := validateCacheKey(key); err != nil {
+		return SyncResult{}, err
+	}
+
+	raw, err := os.ReadFile(c.path(key, cacheMetadataExt))
+	if err != nil {
+		return SyncResult{}, fmt.Errorf("reading cache metadata: %w", err)
+	}
+
+	var result SyncResult
+	if err = json.Unmarshal(raw, &result); err != nil {
+		return SyncResult{}, fmt.Errorf("unmarshal cache metadata: %w", err)
+	}
+
+	result.Path = c.path(key, cacheContentExt)
+	if _, err = os.Stat(result.Path); err != nil {
+		return SyncResult{}, fmt.Errorf("checking cached content: %w", err)
+	}
+
+	return result, nil
+}
+
+// Open opens the cached content under the key
+func (c *Cache) Open(key string) (io.ReadCloser, error) {
+	result, err := c.Result(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(result.Path)
+}
+
+// Load parses the cached content under the key. The name of the source is
+// passed with WithFileName, so the snapshot has the same PKD version as when
+// it is loaded from the source.
+func (c *Cache) Load(ctx context.Context, key string, opts ...Option) (LDIF, error) {
+	result, err := c.Result(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromFileContext(ctx, result.Path, append([]Option{WithFileName(result.Name)}, opts...)...)
+}
+
+// download writes the content into a temporary file, hashing it
+func (c *Cache) download(key string, content io.Reader) (string, string, error) {
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return "", "", fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer tmp.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(tmp, hash), content); err != nil {
+		os.Remove(tmp.Name())
+		return "", "", fmt.Errorf("downloading content: %w", err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", "", fmt.Errorf("closing temporary file: %w", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), tmp.Name(), nil
+}
+
+func (c *Cache) saveResult(key string, result SyncResult) error {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("marshal cache metadata: %w", err)
+	}
+
+	if err = os.WriteFile(c.path(key, cacheMetadataExt), raw, 0o644); err != nil {
+		return fmt.Errorf("writing cache metadata: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Cache) path(key, ext string) string {
+	return filepath.Join(c.dir, key+ext)
+}
+
+func validateCacheKey(key string) error {
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return fmt.Errorf("invalid cache key %q", key)
+	}
+
+	return nil
+}
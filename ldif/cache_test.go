@@ -0,0 +1,148 @@
+package ldif
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheConditional(t *testing.T) {
+	var (
+		content   = ldifData
+		etag      = `"v1"`
+		downloads int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		downloads++
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	cache, err := NewCache(filepath.Join(t.TempDir(), "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := NewHTTPSource(server.URL)
+
+	first, err := cache.Sync(context.Background(), "icao", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, first.Unchanged)
+	assert.Equal(t, etag, first.Validators.ETag)
+
+	converter, err := FromFile(first.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, converter.ToX509(), 2)
+
+	second, err := cache.Sync(context.Background(), "icao", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, second.Unchanged)
+	assert.Equal(t, first.Hash, second.Hash)
+	assert.Equal(t, 1, downloads)
+
+	content, etag = ldifData2, `"v2"`
+
+	third, err := cache.Sync(context.Background(), "icao", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, third.Unchanged)
+	assert.NotEqual(t, first.Hash, third.Hash)
+	assert.Equal(t, 2, downloads)
+
+	cached, err := os.ReadFile(third.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ldifData2, string(cached))
+}
+
+func TestCacheHash(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "icao.ldif")
+	if err := os.WriteFile(filename, []byte(ldifData), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := NewCache(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := NewFileSource(filename)
+
+	first, err := cache.Sync(context.Background(), "icao", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, first.Unchanged)
+
+	second, err := cache.Sync(context.Background(), "icao", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, second.Unchanged)
+	assert.Equal(t, first.UpdatedAt, second.UpdatedAt)
+
+	result, err := cache.Result("icao")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, first.Hash, result.Hash)
+
+	_, err = cache.Result("missing")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = cache.Sync(context.Background(), "../icao", src)
+	assert.Error(t, err)
+}
+
+func TestCacheLoad(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "icaopkd-002-complete-000305.ldif")
+	if err := os.WriteFile(filename, []byte(ldifData), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := NewCache(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := NewFileSource(filename)
+
+	if _, err = cache.Sync(context.Background(), "icao", src); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := cache.Sync(context.Background(), "icao", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, result.Unchanged)
+	assert.Equal(t, filename, result.Name)
+
+	converter, err := cache.Load(context.Background(), "icao")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 305, converter.PKDVersion())
+	assert.Len(t, converter.ToX509(), 2)
+
+	_, err = cache.Load(context.Background(), "missing")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
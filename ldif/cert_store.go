@@ -0,0 +1,252 @@
+package ldif
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+	"github.com/rarimo/certificate-transparency-go/x509/pkix"
+)
+
+// CertStore indexes certificates by subject and authority key identifiers,
+// subject and issuer DN, serial number, fingerprint and country. Lookups return
+// all the candidates in the order the certificates were added, issuance is not
+// verified. DNs are matched case-insensitively and regardless of the string
+// type and order of the attribute values.
+//
+// Lookups are safe for concurrent use, as long as no certificates are added.
+type CertStore struct {
+	certs         []*x509.Certificate
+	byFingerprint map[string]int
+	bySKI         map[string][]int
+	byAKI         map[string][]int
+	bySubject     map[string][]int
+	byIssuer      map[string][]int
+	bySerial      map[string][]int
+	byCountry     map[string][]int
+}
+
+// NewCertStore creates a store with the certificates, duplicates are skipped
+func NewCertStore(certs []*x509.Certificate) *CertStore {
+	s := &CertStore{
+		byFingerprint: make(map[string]int),
+		bySKI:         make(map[string][]int),
+		byAKI:         make(map[string][]int),
+		bySubject:     make(map[string][]int),
+		byIssuer:      make(map[string][]int),
+		bySerial:      make(map[string][]int),
+		byCountry:     make(map[string][]int),
+	}
+
+	for _, cert := range certs {
+		s.Add(cert)
+	}
+
+	return s
+}
+
+// NewCertStoreFromLDIF creates a store with the master list certificates and
+// DSCs of the LDIF
+func NewCertStoreFromLDIF(l LDIF) *CertStore {
+	s := NewCertStore(l.ToX509())
+	for _, signer := range l.DocumentSigners() {
+		s.Add(signer.Certificate)
+	}
+
+	return s
+}
+
+// Add adds the certificate to the store, it returns false when the
+// certificate is there already
+func (s *CertStore) Add(cert *x509.Certificate) bool {
+	fingerprint := Fingerprint(cert)
+	if _, ok := s.byFingerprint[fingerprint]; ok {
+		return false
+	}
+
+	i := len(s.certs)
+	s.certs = append(s.certs, cert)
+	s.byFingerprint[fingerprint] = i
+
+	if len(cert.SubjectKeyId) != 0 {
+		ski := keyIDKey(cert.SubjectKeyId)
+		s.bySKI[ski] = append(s.bySKI[ski], i)
+	}
+	if len(cert.AuthorityKeyId) != 0 {
+		aki := keyIDKey(cert.AuthorityKeyId)
+		s.byAKI[aki] = append(s.byAKI[aki], i)
+	}
+
+	subject, issuer := nameKey(cert.Subject), nameKey(cert.Issuer)
+	s.bySubject[subject] = append(s.bySubject[subject], i)
+	s.byIssuer[issuer] = append(s.byIssuer[issuer], i)
+
+	if cert.SerialNumber != nil {
+		serial := cert.SerialNumber.String()
+		s.bySerial[serial] = append(s.bySerial[serial], i)
+	}
+
+	for _, country := range certificateCountries(cert) {
+		s.byCountry[country] = append(s.byCountry[country], i)
+	}
+
+	return true
+}
+
+// Len returns the number of certificates in the store
+func (s *CertStore) Len() int {
+	return len(s.certs)
+}
+
+// Certificates returns all the certificates in the order they were added
+func (s *CertStore) Certificates() []*x509.Certificate {
+	return append([]*x509.Certificate{}, s.certs...)
+}
+
+// ByFingerprint returns the certificate with the hex-encoded SHA-256
+// fingerprint, see Fingerprint, or nil when it is not in the store
+func (s *CertStore) ByFingerprint(fingerprint string) *x509.Certificate {
+	i, ok := s.byFingerprint[strings.ToLower(fingerprint)]
+	if !ok {
+		return nil
+	}
+
+	return s.certs[i]
+}
+
+// BySKI returns the certificates with the subject key identifier
+func (s *CertStore) BySKI(ski []byte) []*x509.Certificate {
+	return s.lookup(s.bySKI[keyIDKey(ski)])
+}
+
+// ByAKI returns the certificates with the authority key identifier, i.e. the
+// ones issued with the key of the identifier
+func (s *CertStore) ByAKI(aki []byte) []*x509.Certificate {
+	return s.lookup(s.byAKI[keyIDKey(aki)])
+}
+
+// BySubject returns the certificates with the subject DN
+func (s *CertStore) BySubject(name pkix.Name) []*x509.Certificate {
+	return s.lookup(s.bySubject[nameKey(name)])
+}
+
+// ByIssuer returns the certificates with the issuer DN
+func (s *CertStore) ByIssuer(name pkix.Name) []*x509.Certificate {
+	return s.lookup(s.byIssuer[nameKey(name)])
+}
+
+// BySerial returns the certificates with the serial number
+func (s *CertStore) BySerial(serial *big.Int) []*x509.Certificate {
+	return s.lookup(s.bySerial[serial.String()])
+}
+
+// ByIssuerAndSerial returns the certificates with the issuer DN and the
+// serial number, like the ones referenced by CMS IssuerAndSerialNumber
+func (s *CertStore) ByIssuerAndSerial(issuer pkix.Name, serial *big.Int) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for _, cert := range s.ByIssuer(issuer) {
+		if cert.SerialNumber != nil && cert.SerialNumber.Cmp(serial) == 0 {
+			certs = append(certs, cert)
+		}
+	}
+
+	return certs
+}
+
+// ByCountry returns the certificates with the subject country, the country
+// code is case-insensitive
+func (s *CertStore) ByCountry(country string) []*x509.Certificate {
+	return s.lookup(s.byCountry[strings.ToUpper(country)])
+}
+
+// Issuers returns the candidate issuers of the certificate: the certificates
+// with the subject key identifier equal to its authority key identifier and
+// the ones with the subject DN equal to its issuer DN. Self-signed
+// certificates are candidates of their own.
+func (s *CertStore) Issuers(cert *x509.Certificate) []*x509.Certificate {
+	var indexes []int
+	if len(cert.AuthorityKeyId) != 0 {
+		indexes = s.bySKI[keyIDKey(cert.AuthorityKeyId)]
+	}
+
+	return s.lookup(unionIndexes(indexes, s.bySubject[nameKey(cert.Issuer)]))
+}
+
+// Issued returns the candidate certificates issued by the certificate, by its
+// subject key identifier and subject DN, see Issuers
+func (s *CertStore) Issued(cert *x509.Certificate) []*x509.Certificate {
+	var indexes []int
+	if len(cert.SubjectKeyId) != 0 {
+		indexes = s.byAKI[keyIDKey(cert.SubjectKeyId)]
+	}
+
+	return s.lookup(unionIndexes(indexes, s.byIssuer[nameKey(cert.Subject)]))
+}
+
+func (s *CertStore) lookup(indexes []int) []*x509.Certificate {
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	certs := make([]*x509.Certificate, len(indexes))
+	for i, index := range indexes {
+		certs[i] = s.certs[index]
+	}
+
+	return certs
+}
+
+// unionIndexes merges two ascending index lists into one without duplicates
+func unionIndexes(a, b []int) []int {
+	union := make([]int, 0, len(a)+len(b))
+	for len(a) != 0 || len(b) != 0 {
+		switch {
+		case len(b) == 0 || len(a) != 0 && a[0] < b[0]:
+			union, a = append(union, a[0]), a[1:]
+		case len(a) == 0 || b[0] < a[0]:
+			union, b = append(union, b[0]), b[1:]
+		default:
+			union, a, b = append(union, a[0]), a[1:], b[1:]
+		}
+	}
+
+	return union
+}
+
+func keyIDKey(id []byte) string {
+	return hex.EncodeToString(id)
+}
+
+// nameKey normalizes the DN for matching: attribute values are trimmed and
+// lowercased, their string types and order are dropped
+func nameKey(name pkix.Name) string {
+	attributes := name.Names
+	if len(attributes) == 0 {
+		for _, rdn := range name.ToRDNSequence() {
+			attributes = append(attributes, rdn...)
+		}
+	}
+
+	parts := make([]string, len(attributes))
+	for i, attr := range attributes {
+		value := strings.ToLower(strings.TrimSpace(fmt.Sprint(attr.Value)))
+		parts[i] = attr.Type.String() + "=" + value
+	}
+
+	sort.Strings(parts)
+
+	return strings.Join(parts, ",")
+}
+
+// certificateCountries returns the upper-case subject countries of the certificate
+func certificateCountries(cert *x509.Certificate) []string {
+	countries := make([]string, 0, len(cert.Subject.Country))
+	for _, country := range cert.Subject.Country {
+		countries = append(countries, strings.ToUpper(strings.TrimSpace(country)))
+	}
+
+	return countries
+}
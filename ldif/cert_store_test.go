@@ -0,0 +1,87 @@
+package ldif_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+	"github.com/rarimo/certificate-transparency-go/x509/pkix"
+	"github.com/rarimo/ldif-sdk/ldif"
+	"github.com/rarimo/ldif-sdk/ldif/ldiftest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertStore(t *testing.T) {
+	pki, err := ldiftest.New(ldiftest.WithKeyType(ldiftest.ECDSAP256), ldiftest.WithGenerations(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := pki.LDIF()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := ldif.NewLDIF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := ldif.NewCertStoreFromLDIF(l)
+	// 2 CSCAs, link and DSC of each country
+	assert.Equal(t, 8, store.Len())
+
+	xa := pki.Country("XA")
+	var (
+		oldCSCA = xa.CSCAs[0].Certificate
+		newCSCA = xa.CSCA().Certificate
+		link    = xa.Links[0]
+		dsc     = xa.DocumentSigners[0].Certificate
+	)
+
+	assert.False(t, store.Add(dsc))
+	assert.Equal(t, 8, store.Len())
+
+	assert.Equal(t, dsc, store.ByFingerprint(ldif.Fingerprint(dsc)))
+	assert.Equal(t, dsc, store.ByFingerprint(strings.ToUpper(ldif.Fingerprint(dsc))))
+	assert.Nil(t, store.ByFingerprint(ldif.Fingerprint(pki.Country("XB").MasterListSigner.Certificate)))
+
+	assertCertificates(t, []*x509.Certificate{newCSCA, link}, store.BySKI(newCSCA.SubjectKeyId))
+	assertCertificates(t, []*x509.Certificate{dsc}, store.ByAKI(newCSCA.SubjectKeyId))
+	assertCertificates(t, []*x509.Certificate{link}, store.ByAKI(oldCSCA.SubjectKeyId))
+
+	assertCertificates(t, []*x509.Certificate{newCSCA, link}, store.BySubject(newCSCA.Subject))
+	// DNs are matched regardless of case
+	assertCertificates(t, []*x509.Certificate{newCSCA, link}, store.BySubject(pkix.Name{
+		Country:      []string{"xa"},
+		Organization: []string{"GOVERNMENT OF XA"},
+		CommonName:   "csca xa 2",
+	}))
+	assertCertificates(t, []*x509.Certificate{oldCSCA, link}, store.ByIssuer(oldCSCA.Subject))
+
+	assert.Len(t, store.BySerial(dsc.SerialNumber), 2)
+	assertCertificates(t, []*x509.Certificate{dsc}, store.ByIssuerAndSerial(dsc.Issuer, dsc.SerialNumber))
+
+	assert.Len(t, store.ByCountry("xa"), 4)
+	assert.Len(t, store.ByCountry("XB"), 4)
+	assert.Empty(t, store.ByCountry("XC"))
+
+	assertCertificates(t, []*x509.Certificate{newCSCA, link}, store.Issuers(dsc))
+	assertCertificates(t, []*x509.Certificate{oldCSCA}, store.Issuers(link))
+	assertCertificates(t, []*x509.Certificate{oldCSCA, link}, store.Issued(oldCSCA))
+	assertCertificates(t, []*x509.Certificate{newCSCA, dsc}, store.Issued(newCSCA))
+}
+
+func assertCertificates(t *testing.T, expected, actual []*x509.Certificate) {
+	t.Helper()
+
+	fingerprints := func(certs []*x509.Certificate) []string {
+		var result []string
+		for _, cert := range certs {
+			result = append(result, ldif.Fingerprint(cert))
+		}
+		return result
+	}
+
+	assert.ElementsMatch(t, fingerprints(expected), fingerprints(actual))
+}
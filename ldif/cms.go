@@ -0,0 +1,231 @@
+package ldif
+
+import (
+	"bytes"
+	stdasn1 "encoding/asn1"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/github/smimesign/ietf-cms/oid"
+	"github.com/github/smimesign/ietf-cms/protocol"
+	"github.com/rarimo/certificate-transparency-go/asn1"
+	"github.com/rarimo/certificate-transparency-go/x509"
+	"github.com/rarimo/certificate-transparency-go/x509/pkix"
+)
+
+var (
+	// ErrNoSigner is returned when SignedData has no SignerInfo or the signer
+	// certificate is not embedded into it
+	ErrNoSigner = errors.New("signer not found")
+	// ErrInvalidSignature is returned when the signature or the signed
+	// attributes of SignedData do not match the content
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// signatureAlgorithmsByDigest maps a bare public key algorithm, that is often
+// used as SignerInfo signatureAlgorithm, and digest algorithm to x509 algorithm
+var signatureAlgorithmsByDigest = map[string]map[string]x509.SignatureAlgorithm{
+	oid.PublicKeyAlgorithmRSA.String(): {
+		oid.DigestAlgorithmSHA1.String():   x509.SHA1WithRSA,
+		oid.DigestAlgorithmSHA256.String(): x509.SHA256WithRSA,
+		oid.DigestAlgorithmSHA384.String(): x509.SHA384WithRSA,
+		oid.DigestAlgorithmSHA512.String(): x509.SHA512WithRSA,
+	},
+	oid.SignatureAlgorithmRSAPSS.String(): {
+		oid.DigestAlgorithmSHA256.String(): x509.SHA256WithRSAPSS,
+		oid.DigestAlgorithmSHA384.String(): x509.SHA384WithRSAPSS,
+		oid.DigestAlgorithmSHA512.String(): x509.SHA512WithRSAPSS,
+	},
+	oid.PublicKeyAlgorithmECDSA.String(): {
+		oid.DigestAlgorithmSHA1.String():   x509.ECDSAWithSHA1,
+		oid.DigestAlgorithmSHA256.String(): x509.ECDSAWithSHA256,
+		oid.DigestAlgorithmSHA384.String(): x509.ECDSAWithSHA384,
+		oid.DigestAlgorithmSHA512.String(): x509.ECDSAWithSHA512,
+	},
+}
+
+// berMu serializes protocol.BER2DER, as it updates a package variable
+var berMu sync.Mutex
+
+// parseSignedData unwraps CMS ContentInfo and returns SignedData with its
+// encapsulated content. DER input is unmarshalled directly, so master lists are
+// parsed in parallel, only BER input is converted to DER first.
+func parseSignedData(rawData []byte) (*protocol.SignedData, []byte, error) {
+	signedData, encapData, err := unmarshalSignedData(rawData)
+	if err == nil {
+		return signedData, encapData, nil
+	}
+
+	berMu.Lock()
+	der, berErr := protocol.BER2DER(rawData)
+	berMu.Unlock()
+	if berErr != nil {
+		return nil, nil, fmt.Errorf("convert BER to DER: %w", berErr)
+	}
+
+	return unmarshalSignedData(der)
+}
+
+// unmarshalSignedData parses DER ContentInfo with SignedData
+func unmarshalSignedData(der []byte) (*protocol.SignedData, []byte, error) {
+	var ci protocol.ContentInfo
+	rest, err := stdasn1.Unmarshal(der, &ci)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse content info: %w", err)
+	}
+	if len(rest) > 0 {
+		return nil, nil, fmt.Errorf("parse content info: %w", protocol.ErrTrailingData)
+	}
+
+	signedData, err := ci.SignedDataContent()
+	if err != nil {
+		return nil, nil, fmt.Errorf("extract signed data content: %w", err)
+	}
+
+	encapData, err := signedData.EncapContentInfo.EContentValue()
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse encapsulated content: %w", err)
+	}
+
+	return signedData, encapData, nil
+}
+
+// verifySignedData verifies signatures of all the SignerInfos over the content
+// with the embedded certificates and returns the certificate of the first signer
+func verifySignedData(signedData *protocol.SignedData, content []byte) (*x509.Certificate, error) {
+	if len(signedData.SignerInfos) == 0 {
+		return nil, ErrNoSigner
+	}
+
+	certs, err := embeddedCertificates(signedData)
+	if err != nil {
+		return nil, err
+	}
+
+	var signer *x509.Certificate
+	for i, si := range signedData.SignerInfos {
+		cert, err := findSignerCertificate(si, certs)
+		if err != nil {
+			return nil, fmt.Errorf("signer info %d: %w", i, err)
+		}
+
+		if err = verifySignerInfo(si, signedData.EncapContentInfo.EContentType, content, cert); err != nil {
+			return nil, fmt.Errorf("signer info %d: %w", i, err)
+		}
+
+		if signer == nil {
+			signer = cert
+		}
+	}
+
+	return signer, nil
+}
+
+func embeddedCertificates(signedData *protocol.SignedData) ([]*x509.Certificate, error) {
+	certs := make([]*x509.Certificate, 0, len(signedData.Certificates))
+	for _, raw := range signedData.Certificates {
+		if raw.Class != stdasn1.ClassUniversal || raw.Tag != stdasn1.TagSequence {
+			continue
+		}
+
+		cert, err := parseCertificate(raw.FullBytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse embedded certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
+
+func findSignerCertificate(si protocol.SignerInfo, certs []*x509.Certificate) (*x509.Certificate, error) {
+	switch {
+	case si.SID.Class == stdasn1.ClassUniversal && si.SID.Tag == stdasn1.TagSequence:
+		var isn protocol.IssuerAndSerialNumber
+		if _, err := stdasn1.Unmarshal(si.SID.FullBytes, &isn); err != nil {
+			return nil, fmt.Errorf("unmarshal issuer and serial number: %w", err)
+		}
+
+		for _, cert := range certs {
+			if bytes.Equal(cert.RawIssuer, isn.Issuer.FullBytes) && isn.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return cert, nil
+			}
+		}
+	case si.SID.Class == stdasn1.ClassContextSpecific && si.SID.Tag == 0:
+		for _, cert := range certs {
+			if bytes.Equal(cert.SubjectKeyId, si.SID.Bytes) {
+				return cert, nil
+			}
+		}
+	}
+
+	return nil, ErrNoSigner
+}
+
+func verifySignerInfo(si protocol.SignerInfo, contentType stdasn1.ObjectIdentifier, content []byte, cert *x509.Certificate) error {
+	hash, err := si.Hash()
+	if err != nil {
+		return fmt.Errorf("digest algorithm %s: %w", si.DigestAlgorithm.Algorithm, err)
+	}
+
+	signedMessage := content
+	if si.SignedAttrs != nil {
+		siContentType, err := si.GetContentTypeAttribute()
+		if err != nil {
+			return fmt.Errorf("get content type attribute: %w", err)
+		}
+		if !siContentType.Equal(contentType) {
+			return fmt.Errorf("content type attribute mismatch: %w", ErrInvalidSignature)
+		}
+
+		messageDigest, err := si.GetMessageDigestAttribute()
+		if err != nil {
+			return fmt.Errorf("get message digest attribute: %w", err)
+		}
+
+		digest := hash.New()
+		digest.Write(content)
+		if !bytes.Equal(messageDigest, digest.Sum(nil)) {
+			return fmt.Errorf("message digest mismatch: %w", ErrInvalidSignature)
+		}
+
+		if signedMessage, err = si.SignedAttrs.MarshaledForVerification(); err != nil {
+			return fmt.Errorf("marshal signed attributes: %w", err)
+		}
+	}
+
+	algo := signatureAlgorithm(si)
+	if algo == x509.UnknownSignatureAlgorithm {
+		return fmt.Errorf("unsupported signature algorithm %s", si.SignatureAlgorithm.Algorithm)
+	}
+
+	if err = cert.CheckSignature(algo, signedMessage, si.Signature); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+
+	return nil
+}
+
+func signatureAlgorithm(si protocol.SignerInfo) x509.SignatureAlgorithm {
+	ai := pkix.AlgorithmIdentifier{
+		Algorithm:  asn1.ObjectIdentifier(si.SignatureAlgorithm.Algorithm),
+		Parameters: asn1.RawValue{FullBytes: si.SignatureAlgorithm.Parameters.FullBytes},
+	}
+
+	if algo := x509.SignatureAlgorithmFromAI(ai); algo != x509.UnknownSignatureAlgorithm {
+		return algo
+	}
+
+	return signatureAlgorithmsByDigest[ai.Algorithm.String()][si.DigestAlgorithm.Algorithm.String()]
+}
+
+// issuedBy checks whether the certificate is signed by the issuer
+func issuedBy(cert, issuer *x509.Certificate) bool {
+	if len(cert.AuthorityKeyId) != 0 && len(issuer.SubjectKeyId) != 0 &&
+		!bytes.Equal(cert.AuthorityKeyId, issuer.SubjectKeyId) {
+		return false
+	}
+
+	return cert.CheckSignatureFrom(issuer) == nil
+}
@@ -0,0 +1,224 @@
+package ldif
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha1" // for crypto.SHA1
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/rarimo/certificate-transparency-go/asn1"
+	"github.com/rarimo/certificate-transparency-go/x509"
+	"github.com/rarimo/certificate-transparency-go/x509/pkix"
+)
+
+const deviationListContentAttr = "pkdDeviationListContent"
+
+// Deviation types defined in ICAO Doc 9303 Part 12
+var (
+	OIDDeviationList = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 7}
+
+	OIDDeviationCertOrKey                 = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 7, 1}
+	OIDDeviationCertOrKeyDSSignature      = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 7, 1, 1}
+	OIDDeviationCertOrKeyDSEncoding       = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 7, 1, 2}
+	OIDDeviationCertOrKeyCSCAEncoding     = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 7, 1, 3}
+	OIDDeviationCertOrKeyAAKeyCompromised = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 7, 1, 4}
+	OIDDeviationLDS                       = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 7, 2}
+	OIDDeviationLDSDGMalformed            = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 7, 2, 1}
+	OIDDeviationLDSDGHashWrong            = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 7, 2, 2}
+	OIDDeviationLDSSODSignatureWrong      = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 7, 2, 3}
+	OIDDeviationLDSCOMInconsistent        = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 7, 2, 4}
+	OIDDeviationMRZ                       = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 7, 3}
+	OIDDeviationMRZWrongData              = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 7, 3, 1}
+	OIDDeviationMRZWrongCheckDigit        = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 7, 3, 2}
+	OIDDeviationChip                      = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 7, 4}
+	OIDDeviationNationalUse               = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 7, 5}
+)
+
+// Tags of DocumentSignerIdentifier CHOICE
+const (
+	signerIdentifierIssuerAndSerialTag = 1
+	signerIdentifierSKITag             = 2
+	signerIdentifierDigestTag          = 4
+)
+
+var digestAlgorithms = map[string]crypto.Hash{
+	"1.3.14.3.2.26":          crypto.SHA1,
+	"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+	"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+	"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+	"2.16.840.1.101.3.4.2.4": crypto.SHA224,
+}
+
+// DeviationList represents ICAO Deviation List describing known defects of
+// issued documents and certificates. See ICAO Doc 9303 Part 12 for more info.
+type DeviationList struct {
+	Version    int
+	DigestAlg  pkix.AlgorithmIdentifier `asn1:"optional"`
+	Deviations []Deviation              `asn1:"set"`
+}
+
+// Deviation binds the set of documents to the descriptions of their defects
+type Deviation struct {
+	Documents    DeviationDocuments
+	Descriptions []DeviationDescription `asn1:"set"`
+}
+
+// DeviationDescription describes a single defect
+type DeviationDescription struct {
+	Description   string `asn1:"optional,printable"`
+	DeviationType asn1.ObjectIdentifier
+	Parameters    asn1.RawValue `asn1:"optional,explicit,tag:0"`
+	NationalUse   asn1.RawValue `asn1:"optional,explicit,tag:1"`
+}
+
+// DeviationDocuments identifies documents affected by the deviation
+type DeviationDocuments struct {
+	DocumentType    string         `asn1:"optional,printable,tag:0"`
+	DSCIdentifier   asn1.RawValue  `asn1:"optional,explicit,tag:1"`
+	IssuingDate     IssuancePeriod `asn1:"optional,tag:2"`
+	DocumentNumbers []string       `asn1:"optional,set,tag:3"`
+}
+
+// IssuancePeriod is the period when the affected documents were issued
+type IssuancePeriod struct {
+	FirstIssued time.Time `asn1:"generalized"`
+	LastIssued  time.Time `asn1:"generalized"`
+}
+
+// DocumentSignerIdentifier is the decoded DocumentSignerIdentifier CHOICE,
+// only one of the fields is set
+type DocumentSignerIdentifier struct {
+	// Issuer is DER-encoded issuer name, set along with SerialNumber
+	Issuer            []byte
+	SerialNumber      *big.Int
+	SubjectKeyID      []byte
+	CertificateDigest []byte
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// DeviationListEntry is a deviation list read from LDIF along with its country
+type DeviationListEntry struct {
+	Country string
+	DN      string
+	List    DeviationList
+	// PKDVersion is the pkdVersion of LDIF entry, 0 when absent
+	PKDVersion int
+}
+
+// ParseDeviationList parses a single CMS-encoded deviation list
+func ParseDeviationList(rawData []byte) (DeviationList, error) {
+	_, encapData, err := parseSignedData(rawData)
+	if err != nil {
+		return DeviationList{}, err
+	}
+
+	var list DeviationList
+	_, err = asn1.Unmarshal(encapData, &list)
+	if err != nil {
+		return DeviationList{}, fmt.Errorf("unmarshal ASN.1 deviation list: %w", err)
+	}
+
+	return list, nil
+}
+
+// SignerIdentifier decodes the DSC identifier of affected documents. It
+// returns nil when the documents are not identified by the document signer.
+func (d DeviationDocuments) SignerIdentifier() (*DocumentSignerIdentifier, error) {
+	if len(d.DSCIdentifier.Bytes) == 0 {
+		return nil, nil
+	}
+
+	var choice asn1.RawValue
+	if _, err := asn1.Unmarshal(d.DSCIdentifier.Bytes, &choice); err != nil {
+		return nil, fmt.Errorf("unmarshal document signer identifier: %w", err)
+	}
+	if choice.Class != asn1.ClassContextSpecific {
+		return nil, fmt.Errorf("unexpected document signer identifier class %d", choice.Class)
+	}
+
+	switch choice.Tag {
+	case signerIdentifierIssuerAndSerialTag:
+		var isn issuerAndSerialNumber
+		if _, err := asn1.UnmarshalWithParams(choice.FullBytes, &isn, "tag:1"); err != nil {
+			return nil, fmt.Errorf("unmarshal issuer and serial number: %w", err)
+		}
+
+		return &DocumentSignerIdentifier{Issuer: isn.Issuer.FullBytes, SerialNumber: isn.SerialNumber}, nil
+	case signerIdentifierSKITag:
+		return &DocumentSignerIdentifier{SubjectKeyID: choice.Bytes}, nil
+	case signerIdentifierDigestTag:
+		return &DocumentSignerIdentifier{CertificateDigest: choice.Bytes}, nil
+	default:
+		return nil, fmt.Errorf("unknown document signer identifier tag %d", choice.Tag)
+	}
+}
+
+// Find returns deviations of the documents signed by the certificate. The
+// certificate may be either a DSC or a CSCA, as both can be referenced.
+func (dl DeviationList) Find(cert *x509.Certificate) ([]Deviation, error) {
+	var found []Deviation
+
+	for _, deviation := range dl.Deviations {
+		id, err := deviation.Documents.SignerIdentifier()
+		if err != nil {
+			return nil, err
+		}
+		if id == nil {
+			continue
+		}
+
+		matches, err := dl.matches(id, cert)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			found = append(found, deviation)
+		}
+	}
+
+	return found, nil
+}
+
+func (dl DeviationList) matches(id *DocumentSignerIdentifier, cert *x509.Certificate) (bool, error) {
+	switch {
+	case id.SerialNumber != nil:
+		return bytes.Equal(id.Issuer, cert.RawIssuer) && id.SerialNumber.Cmp(cert.SerialNumber) == 0, nil
+	case id.SubjectKeyID != nil:
+		return bytes.Equal(id.SubjectKeyID, cert.SubjectKeyId), nil
+	case id.CertificateDigest != nil:
+		hash := crypto.SHA256
+		if len(dl.DigestAlg.Algorithm) != 0 {
+			var ok bool
+			if hash, ok = digestAlgorithms[dl.DigestAlg.Algorithm.String()]; !ok {
+				return false, fmt.Errorf("unsupported digest algorithm %s", dl.DigestAlg.Algorithm)
+			}
+		}
+
+		digest := hash.New()
+		digest.Write(cert.Raw)
+		return bytes.Equal(id.CertificateDigest, digest.Sum(nil)), nil
+	}
+
+	return false, nil
+}
+
+func parseDeviationListEntry(dn string, value []byte) (DeviationListEntry, error) {
+	list, err := ParseDeviationList(value)
+	if err != nil {
+		return DeviationListEntry{}, fmt.Errorf("parse deviation list: %w", err)
+	}
+
+	return DeviationListEntry{
+		Country: countryFromDN(dn),
+		DN:      dn,
+		List:    list,
+	}, nil
+}
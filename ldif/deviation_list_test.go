@@ -0,0 +1,124 @@
+package ldif
+
+import (
+	"crypto/sha256"
+	stdasn1 "encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/github/smimesign/ietf-cms/protocol"
+	"github.com/rarimo/certificate-transparency-go/asn1"
+	"github.com/rarimo/certificate-transparency-go/x509"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviationLists(t *testing.T) {
+	certs := make([]*x509.Certificate, len(PEMCerts))
+	for i, rawPem := range PEMCerts {
+		block, _ := pem.Decode([]byte(rawPem))
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs[i] = cert
+	}
+
+	digest := sha256.Sum256(certs[1].Raw)
+	list := DeviationList{
+		Deviations: []Deviation{
+			{
+				Documents: DeviationDocuments{
+					DocumentType:  "P",
+					DSCIdentifier: signerIdentifier(t, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, Bytes: certs[0].SubjectKeyId}),
+					IssuingDate: IssuancePeriod{
+						FirstIssued: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+						LastIssued:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+					},
+				},
+				Descriptions: []DeviationDescription{{
+					Description:   "wrong DG hash",
+					DeviationType: OIDDeviationLDSDGHashWrong,
+				}},
+			},
+			{
+				Documents: DeviationDocuments{
+					DSCIdentifier: signerIdentifier(t, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 4, Bytes: digest[:]}),
+				},
+				Descriptions: []DeviationDescription{{DeviationType: OIDDeviationCertOrKeyCSCAEncoding}},
+			},
+			{
+				Documents: DeviationDocuments{
+					DocumentNumbers: []string{"AB1234567"},
+				},
+				Descriptions: []DeviationDescription{{DeviationType: OIDDeviationMRZWrongCheckDigit}},
+			},
+		},
+	}
+
+	data := fmt.Sprintf(`dn: cn=CN\=CSCA-BWA\,C\=BW,o=dl,c=BW,dc=data,dc=download,dc=pkd,dc=icao,dc=int
+pkdVersion: 12
+objectClass: pkdDeviationList
+pkdDeviationListContent:: %s
+`, base64.StdEncoding.EncodeToString(deviationListCMS(t, list)))
+
+	converter, err := NewLDIF([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lists := converter.DeviationLists()
+	if !assert.Len(t, lists, 1) {
+		return
+	}
+	assert.Equal(t, "BW", lists[0].Country)
+	assert.Equal(t, []string{"AB1234567"}, lists[0].List.Deviations[2].Documents.DocumentNumbers)
+	assert.Equal(t, list.Deviations[0].Documents.IssuingDate, lists[0].List.Deviations[0].Documents.IssuingDate)
+
+	for i, cert := range certs {
+		deviations, err := converter.DeviationsFor(cert)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if assert.Len(t, deviations, 1) {
+			assert.Equal(t, list.Deviations[i].Descriptions[0].DeviationType, deviations[0].Descriptions[0].DeviationType)
+		}
+	}
+}
+
+func signerIdentifier(t *testing.T, choice asn1.RawValue) asn1.RawValue {
+	inner, err := asn1.Marshal(choice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: inner}
+}
+
+func deviationListCMS(t *testing.T, list DeviationList) []byte {
+	content, err := asn1.Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eci, err := protocol.NewEncapsulatedContentInfo(stdasn1.ObjectIdentifier(OIDDeviationList), content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signedData, err := protocol.NewSignedData(eci)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := signedData.ContentInfoDER()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return der
+}
@@ -0,0 +1,65 @@
+package ldif
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Diagnostic describes an LDIF entry that failed to parse. In lenient mode such
+// entries are skipped and reported with LDIF.Diagnostics, otherwise the first
+// one is returned as an error.
+type Diagnostic struct {
+	// Line is the line where the LDIF record starts, 0 for standalone master lists
+	Line int
+	// DN is the LDIF entry DN, empty when the record itself is malformed
+	DN string
+	// Attribute holds the failed value, empty when the record itself is malformed
+	Attribute string
+	// MasterListIndex is the number of the master list entry in LDIF, counting
+	// the skipped entries, or -1
+	MasterListIndex int
+	// CertificateIndex is the index of the certificate inside of the master
+	// list, -1 when the whole entry failed
+	CertificateIndex int
+	// DER is the raw value that failed to parse
+	DER []byte
+	Err error
+}
+
+func (d Diagnostic) Error() string {
+	var parts []string
+	if d.Line > 0 {
+		parts = append(parts, fmt.Sprintf("line %d", d.Line))
+	}
+	if d.DN != "" {
+		parts = append(parts, fmt.Sprintf("entry %s", d.DN))
+	}
+	if d.MasterListIndex >= 0 {
+		parts = append(parts, fmt.Sprintf("master list %d", d.MasterListIndex))
+	}
+	if d.CertificateIndex >= 0 {
+		parts = append(parts, fmt.Sprintf("certificate %d", d.CertificateIndex))
+	}
+
+	if len(parts) == 0 {
+		return d.Err.Error()
+	}
+
+	return fmt.Sprintf("%s: %s", strings.Join(parts, ", "), d.Err)
+}
+
+func (d Diagnostic) Unwrap() error {
+	return d.Err
+}
+
+func newDiagnostic(record *Record, attribute string, value []byte, err error) Diagnostic {
+	return Diagnostic{
+		Line:             record.Line,
+		DN:               record.DN,
+		Attribute:        attribute,
+		MasterListIndex:  -1,
+		CertificateIndex: -1,
+		DER:              value,
+		Err:              err,
+	}
+}
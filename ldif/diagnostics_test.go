@@ -0,0 +1,130 @@
+package ldif
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLenientParsing(t *testing.T) {
+	records, err := ParseRecords([]byte(ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := records[2].Value(masterListContentAttr)
+	cert := pemCertDER(t, 1)
+
+	// break the version tag of the second certificate inside of the master list,
+	// keeping the master list structure valid
+	offset := bytes.Index(content, cert)
+	corrupted := append([]byte{}, content...)
+	corrupted[offset+8] = 0xa5
+
+	data := fmt.Sprintf(`dn: %s
+pkdMasterListContent:: %s
+
+malformed record
+
+dn: cn=CN\=DS,o=dsc,c=BW,dc=data,dc=download,dc=pkd,dc=icao,dc=int
+userCertificate;binary:: AQID
+
+`, records[2].DN, base64.StdEncoding.EncodeToString(corrupted)) + ldifData2
+
+	converter, err := NewLDIF([]byte(data), WithLenientParsing())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lists := converter.MasterLists()
+	if assert.Len(t, lists, 2) {
+		assert.Equal(t, 0, lists[0].Index)
+		if assert.Len(t, lists[0].Certificates, 1) {
+			assert.Equal(t, pemCertDER(t, 0), lists[0].Certificates[0].Raw)
+		}
+		assert.Equal(t, 1, lists[1].Index)
+	}
+	assert.Empty(t, converter.DocumentSigners())
+
+	diagnostics := converter.Diagnostics()
+	if !assert.Len(t, diagnostics, 3) {
+		return
+	}
+
+	assert.Equal(t, records[2].DN, diagnostics[0].DN)
+	assert.Equal(t, masterListContentAttr, diagnostics[0].Attribute)
+	assert.Equal(t, 0, diagnostics[0].MasterListIndex)
+	assert.Equal(t, 0, diagnostics[0].CertificateIndex)
+	assert.Equal(t, corrupted[offset:offset+len(cert)], diagnostics[0].DER)
+
+	assert.Equal(t, 4, diagnostics[1].Line)
+	assert.Empty(t, diagnostics[1].DN)
+	assert.Equal(t, -1, diagnostics[1].MasterListIndex)
+
+	assert.Equal(t, 6, diagnostics[2].Line)
+	assert.Equal(t, userCertificateAttr, diagnostics[2].Attribute)
+	assert.Equal(t, []byte{1, 2, 3}, diagnostics[2].DER)
+	assert.Equal(t, -1, diagnostics[2].CertificateIndex)
+
+	_, err = NewLDIF([]byte(data))
+	var diagnostic Diagnostic
+	if assert.True(t, errors.As(err, &diagnostic), err) {
+		assert.Equal(t, 0, diagnostic.CertificateIndex)
+	}
+}
+
+func TestLenientMasterListIndex(t *testing.T) {
+	records, err := ParseRecords([]byte(ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the whole master list entry between the valid ones is skipped
+	data := ldifData + fmt.Sprintf(`dn: %s
+pkdMasterListContent:: AQID
+
+`, records[2].DN) + ldifData2
+
+	converter, err := NewLDIF([]byte(data), WithLenientParsing(), WithSignatureVerification())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lists := converter.MasterLists()
+	if !assert.Len(t, lists, 2) {
+		return
+	}
+
+	for i, ml := range lists {
+		assert.Equal(t, i, ml.Index)
+	}
+
+	for _, cert := range converter.ToX509() {
+		provenance := converter.Provenance(cert)
+		assert.NotEmpty(t, provenance)
+
+		for _, p := range provenance {
+			if !assert.Less(t, p.MasterListIndex, len(lists)) {
+				continue
+			}
+
+			ml := lists[p.MasterListIndex]
+			assert.Equal(t, p.DN, ml.DN)
+			assert.Contains(t, ml.Certificates, cert)
+		}
+	}
+
+	for _, v := range converter.Verifications() {
+		assert.Equal(t, lists[v.Index].DN, v.DN)
+	}
+
+	diagnostics := converter.Diagnostics()
+	if assert.Len(t, diagnostics, 1) {
+		// the entry number counts the skipped entries
+		assert.Equal(t, 1, diagnostics[0].MasterListIndex)
+	}
+}
@@ -0,0 +1,244 @@
+package ldif
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+)
+
+// MasterListStatus is the kind of change of country master lists
+type MasterListStatus string
+
+const (
+	MasterListAdded   MasterListStatus = "added"
+	MasterListRemoved MasterListStatus = "removed"
+	MasterListChanged MasterListStatus = "changed"
+)
+
+// SnapshotDiff describes the changes between two LDIF snapshots. All the lists
+// are sorted, so the same snapshots always give the same diff.
+type SnapshotDiff struct {
+	OldVersion int `json:"old_version"`
+	NewVersion int `json:"new_version"`
+	// AddedCertificates and RemovedCertificates are sorted by fingerprint
+	AddedCertificates   []DiffCertificate `json:"added_certificates"`
+	RemovedCertificates []DiffCertificate `json:"removed_certificates"`
+	// AddedPubKeys and RemovedPubKeys are the unique keys as returned by
+	// LDIF.RawPubKeys, sorted bytewise
+	AddedPubKeys   [][]byte `json:"added_pub_keys"`
+	RemovedPubKeys [][]byte `json:"removed_pub_keys"`
+	// MasterLists are the changes of master lists sorted by country
+	MasterLists []MasterListChange `json:"master_lists"`
+}
+
+// DiffCertificate identifies an added or removed certificate
+type DiffCertificate struct {
+	Fingerprint string `json:"fingerprint"`
+	Country     string `json:"country"`
+	Subject     string `json:"subject"`
+	// Raw is DER-encoded certificate
+	Raw []byte `json:"raw"`
+}
+
+// MasterListChange describes the change of master lists issued by the country.
+// Master lists are considered changed when their certificates, PKD versions or
+// signing times differ.
+type MasterListChange struct {
+	Country        string           `json:"country"`
+	Status         MasterListStatus `json:"status"`
+	OldPKDVersion  int              `json:"old_pkd_version"`
+	NewPKDVersion  int              `json:"new_pkd_version"`
+	OldSigningTime time.Time        `json:"old_signing_time"`
+	NewSigningTime time.Time        `json:"new_signing_time"`
+	// AddedCertificates and RemovedCertificates are sorted fingerprints of the
+	// certificates added to and removed from the country master lists
+	AddedCertificates   []string `json:"added_certificates"`
+	RemovedCertificates []string `json:"removed_certificates"`
+}
+
+// IsEmpty reports whether the snapshots have no differences
+func (d SnapshotDiff) IsEmpty() bool {
+	return len(d.AddedCertificates) == 0 && len(d.RemovedCertificates) == 0 &&
+		len(d.AddedPubKeys) == 0 && len(d.RemovedPubKeys) == 0 && len(d.MasterLists) == 0
+}
+
+// Diff compares two snapshots: certificates, unique public keys and master
+// lists of each country
+func Diff(prev, next LDIF) (SnapshotDiff, error) {
+	diff := SnapshotDiff{
+		OldVersion: prev.PKDVersion(),
+		NewVersion: next.PKDVersion(),
+	}
+
+	prevCerts, nextCerts := certificateSet(prev.ToX509()), certificateSet(next.ToX509())
+	diff.AddedCertificates = diffCertificates(nextCerts, prevCerts)
+	diff.RemovedCertificates = diffCertificates(prevCerts, nextCerts)
+
+	prevKeys, err := prev.RawPubKeys()
+	if err != nil {
+		return SnapshotDiff{}, fmt.Errorf("extract old public keys: %w", err)
+	}
+	nextKeys, err := next.RawPubKeys()
+	if err != nil {
+		return SnapshotDiff{}, fmt.Errorf("extract new public keys: %w", err)
+	}
+	diff.AddedPubKeys = diffKeys(nextKeys, prevKeys)
+	diff.RemovedPubKeys = diffKeys(prevKeys, nextKeys)
+
+	diff.MasterLists = diffMasterLists(countryMasterLists(prev.MasterLists()), countryMasterLists(next.MasterLists()))
+
+	return diff, nil
+}
+
+// certificateSet maps fingerprints to the certificates
+func certificateSet(certs []*x509.Certificate) map[string]*x509.Certificate {
+	set := make(map[string]*x509.Certificate, len(certs))
+	for _, cert := range certs {
+		set[Fingerprint(cert)] = cert
+	}
+
+	return set
+}
+
+// diffCertificates returns the certificates of a that are missing in b
+func diffCertificates(a, b map[string]*x509.Certificate) []DiffCertificate {
+	var diff []DiffCertificate
+	for fingerprint, cert := range a {
+		if _, ok := b[fingerprint]; ok {
+			continue
+		}
+
+		item := DiffCertificate{
+			Fingerprint: fingerprint,
+			Subject:     cert.Subject.String(),
+			Raw:         cert.Raw,
+		}
+		if len(cert.Subject.Country) != 0 {
+			item.Country = strings.ToUpper(cert.Subject.Country[0])
+		}
+		diff = append(diff, item)
+	}
+
+	sort.Slice(diff, func(i, j int) bool {
+		return diff[i].Fingerprint < diff[j].Fingerprint
+	})
+
+	return diff
+}
+
+// diffKeys returns the keys of a that are missing in b
+func diffKeys(a, b [][]byte) [][]byte {
+	known := make(map[string]struct{}, len(b))
+	for _, key := range b {
+		known[string(key)] = struct{}{}
+	}
+
+	var diff [][]byte
+	for _, key := range a {
+		if _, ok := known[string(key)]; !ok {
+			diff = append(diff, key)
+		}
+	}
+
+	sort.Slice(diff, func(i, j int) bool {
+		return bytes.Compare(diff[i], diff[j]) < 0
+	})
+
+	return diff
+}
+
+// countryLists is the summary of all master lists of the country
+type countryLists struct {
+	pkdVersion   int
+	signingTime  time.Time
+	certificates map[string]struct{}
+}
+
+func countryMasterLists(lists []MasterList) map[string]*countryLists {
+	countries := make(map[string]*countryLists)
+	for _, ml := range lists {
+		country, ok := countries[ml.Country]
+		if !ok {
+			country = &countryLists{certificates: make(map[string]struct{})}
+			countries[ml.Country] = country
+		}
+
+		if ml.PKDVersion > country.pkdVersion {
+			country.pkdVersion = ml.PKDVersion
+		}
+		if ml.SigningTime.After(country.signingTime) {
+			country.signingTime = ml.SigningTime
+		}
+		for _, cert := range ml.Certificates {
+			country.certificates[Fingerprint(cert)] = struct{}{}
+		}
+	}
+
+	return countries
+}
+
+func diffMasterLists(prev, next map[string]*countryLists) []MasterListChange {
+	var changes []MasterListChange
+
+	for country, old := range prev {
+		if _, ok := next[country]; !ok {
+			changes = append(changes, MasterListChange{
+				Country:             country,
+				Status:              MasterListRemoved,
+				OldPKDVersion:       old.pkdVersion,
+				OldSigningTime:      old.signingTime,
+				RemovedCertificates: diffFingerprints(old.certificates, nil),
+			})
+		}
+	}
+
+	for country, current := range next {
+		change := MasterListChange{
+			Country:        country,
+			Status:         MasterListAdded,
+			NewPKDVersion:  current.pkdVersion,
+			NewSigningTime: current.signingTime,
+		}
+
+		var oldCerts map[string]struct{}
+		if old, ok := prev[country]; ok {
+			change.Status = MasterListChanged
+			change.OldPKDVersion = old.pkdVersion
+			change.OldSigningTime = old.signingTime
+			oldCerts = old.certificates
+		}
+
+		change.AddedCertificates = diffFingerprints(current.certificates, oldCerts)
+		change.RemovedCertificates = diffFingerprints(oldCerts, current.certificates)
+
+		unchanged := change.Status == MasterListChanged &&
+			len(change.AddedCertificates) == 0 && len(change.RemovedCertificates) == 0 &&
+			change.OldPKDVersion == change.NewPKDVersion && change.OldSigningTime.Equal(change.NewSigningTime)
+		if !unchanged {
+			changes = append(changes, change)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Country < changes[j].Country
+	})
+
+	return changes
+}
+
+// diffFingerprints returns sorted fingerprints of a that are missing in b
+func diffFingerprints(a, b map[string]struct{}) []string {
+	var diff []string
+	for fingerprint := range a {
+		if _, ok := b[fingerprint]; !ok {
+			diff = append(diff, fingerprint)
+		}
+	}
+	sort.Strings(diff)
+
+	return diff
+}
@@ -0,0 +1,109 @@
+package ldif
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	bw, err := NewLDIF([]byte(ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	both, err := NewLDIF([]byte(ldifData + ldifData2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := NewLDIF([]byte(ldifData2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := Diff(bw, both)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fiCerts := fi.ToX509()
+	fiKeys, err := fi.RawPubKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 119, diff.OldVersion)
+	assert.Equal(t, 153, diff.NewVersion)
+	assert.Len(t, diff.AddedCertificates, len(fiCerts))
+	assert.Empty(t, diff.RemovedCertificates)
+	assert.ElementsMatch(t, fiKeys, diff.AddedPubKeys)
+	assert.Empty(t, diff.RemovedPubKeys)
+	if assert.Len(t, diff.MasterLists, 1) {
+		change := diff.MasterLists[0]
+		assert.Equal(t, "FI", change.Country)
+		assert.Equal(t, MasterListAdded, change.Status)
+		assert.Equal(t, 153, change.NewPKDVersion)
+		assert.Len(t, change.AddedCertificates, len(fiCerts))
+	}
+
+	for _, cert := range diff.AddedCertificates {
+		assert.Equal(t, "FI", cert.Country)
+	}
+
+	diff, err = Diff(both, fi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, diff.AddedCertificates)
+	assert.Len(t, diff.RemovedCertificates, 2)
+	if assert.Len(t, diff.MasterLists, 1) {
+		assert.Equal(t, "BW", diff.MasterLists[0].Country)
+		assert.Equal(t, MasterListRemoved, diff.MasterLists[0].Status)
+		assert.Len(t, diff.MasterLists[0].RemovedCertificates, 2)
+	}
+
+	// the same master list republished with the new PKD version
+	republished, err := NewLDIF([]byte(strings.Replace(ldifData, "pkdVersion: 119", "pkdVersion: 120", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err = Diff(bw, republished)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, diff.AddedCertificates)
+	assert.Empty(t, diff.AddedPubKeys)
+	if assert.Len(t, diff.MasterLists, 1) {
+		change := diff.MasterLists[0]
+		assert.Equal(t, MasterListChanged, change.Status)
+		assert.Equal(t, 119, change.OldPKDVersion)
+		assert.Equal(t, 120, change.NewPKDVersion)
+		assert.Empty(t, change.AddedCertificates)
+	}
+
+	diff, err = Diff(both, both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, diff.IsEmpty())
+
+	diff, err = Diff(fi, both)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := json.Marshal(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded SnapshotDiff
+	if err = json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, diff.AddedCertificates, decoded.AddedCertificates)
+	assert.Equal(t, diff.AddedPubKeys, decoded.AddedPubKeys)
+	assert.Equal(t, diff.MasterLists[0].Country, decoded.MasterLists[0].Country)
+}
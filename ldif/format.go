@@ -0,0 +1,182 @@
+package ldif
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"unicode"
+)
+
+// contentFormat is the format of the loaded content
+type contentFormat int
+
+const (
+	formatLDIF contentFormat = iota
+	formatGzip
+	formatZip
+	formatCMS
+)
+
+// formatHeaderSize is the number of the first bytes used to detect the format
+const formatHeaderSize = 32
+
+const (
+	// maxArchiveDepth is the number of nested gzip and ZIP layers allowed
+	maxArchiveDepth = 4
+	// defaultMaxDecompressedSize is the default limit of decompressed bytes,
+	// see WithMaxDecompressedSize
+	defaultMaxDecompressedSize = 1 << 30
+)
+
+// ErrContentTooLarge is returned when compressed content expands beyond the
+// limit or archives are nested too deep
+var ErrContentTooLarge = errors.New("content too large")
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+	// signedDataOID is DER-encoded id-signedData (1.2.840.113549.1.7.2) that
+	// follows the headers of CMS ContentInfo
+	signedDataOID = []byte{0x06, 0x09, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x07, 0x02}
+)
+
+// detectFormat detects the format by the first bytes of the content
+func detectFormat(header []byte) contentFormat {
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return formatGzip
+	case bytes.HasPrefix(header, zipMagic):
+		return formatZip
+	case len(header) != 0 && header[0] == 0x30 && bytes.Contains(header, signedDataOID):
+		return formatCMS
+	default:
+		return formatLDIF
+	}
+}
+
+// isLDIF tells whether the header detected as LDIF starts with an LDIF line
+// indeed, it is used to skip other files of the archives
+func isLDIF(header []byte) bool {
+	header = bytes.ToLower(bytes.TrimLeftFunc(bytes.TrimPrefix(header, []byte("\xef\xbb\xbf")), unicode.IsSpace))
+	return bytes.HasPrefix(header, []byte("dn:")) || bytes.HasPrefix(header, []byte("version:")) ||
+		bytes.HasPrefix(header, []byte("#"))
+}
+
+// load reads the content of any supported format: raw LDIF, raw CMS master
+// list, gzip-compressed content or ZIP archive with several members. Entries of
+// all the ZIP members are merged in the archive order, members of other
+// formats are skipped.
+func (ld *loader) load(r io.Reader) error {
+	return ld.loadNested(r, 0)
+}
+
+// loadNested loads the content found at the depth of nested archives
+func (ld *loader) loadNested(r io.Reader, depth int) error {
+	if depth > maxArchiveDepth {
+		return fmt.Errorf("%w: archives are nested deeper than %d levels", ErrContentTooLarge, maxArchiveDepth)
+	}
+
+	br := bufio.NewReader(r)
+	// error is ignored, as the short content is handled by the LDIF parser
+	header, _ := br.Peek(formatHeaderSize)
+
+	switch detectFormat(header) {
+	case formatGzip:
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("opening gzip: %w", err)
+		}
+		defer gz.Close()
+
+		return ld.loadNested(ld.decompressed(gz), depth+1)
+	case formatZip:
+		return ld.loadZip(br, depth)
+	case formatCMS:
+		content, err := io.ReadAll(br)
+		if err != nil {
+			return fmt.Errorf("reading master list: %w", err)
+		}
+
+		record := &Record{}
+		if err = ld.addMasterList(record, 0, ld.decodeMasterList(record, content)); err != nil {
+			return err
+		}
+
+		ld.report()
+		return nil
+	default:
+		return ld.loadLDIF(br)
+	}
+}
+
+// loadZip loads all the file members of the archive. ZIP requires random
+// access, so the archive is read into memory.
+func (ld *loader) loadZip(r io.Reader, depth int) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("reading zip: %w", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return fmt.Errorf("opening zip: %w", err)
+	}
+
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		if err = ld.ctx.Err(); err != nil {
+			return err
+		}
+
+		if err = ld.loadZipMember(file, depth); err != nil {
+			return fmt.Errorf("loading %s: %w", file.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (ld *loader) loadZipMember(file *zip.File, depth int) error {
+	member, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("opening zip member: %w", err)
+	}
+	defer member.Close()
+
+	br := bufio.NewReader(ld.decompressed(member))
+	header, _ := br.Peek(formatHeaderSize)
+	if detectFormat(header) == formatLDIF && !isLDIF(header) {
+		// README, checksums and other files
+		return nil
+	}
+
+	ld.addFileName(file.Name)
+	return ld.loadNested(br, depth+1)
+}
+
+// decompressed counts the bytes read from the decompressor and fails with
+// ErrContentTooLarge when all the decompressed content exceeds the limit
+func (ld *loader) decompressed(r io.Reader) io.Reader {
+	return &decompressedReader{r: r, ld: ld}
+}
+
+type decompressedReader struct {
+	r  io.Reader
+	ld *loader
+}
+
+func (r *decompressedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.ld.decompressedBytes += int64(n)
+	if limit := r.ld.cfg.maxDecompressedSize; r.ld.decompressedBytes > limit {
+		return n, fmt.Errorf("%w: more than %d decompressed bytes", ErrContentTooLarge, limit)
+	}
+
+	return n, err
+}
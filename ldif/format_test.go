@@ -0,0 +1,174 @@
+package ldif
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectFormat(t *testing.T) {
+	records, err := ParseRecords([]byte(ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cms := records[2].Value(masterListContentAttr)
+
+	testCases := []struct {
+		name   string
+		header []byte
+		want   contentFormat
+	}{
+		{name: "ldif", header: []byte(ldifData), want: formatLDIF},
+		{name: "empty", header: nil, want: formatLDIF},
+		{name: "gzip", header: gzipData(t, []byte(ldifData)), want: formatGzip},
+		{name: "zip", header: zipData(t, map[string]string{"a.ldif": ldifData}), want: formatZip},
+		{name: "cms", header: cms, want: formatCMS},
+		{name: "der not cms", header: pemCertDER(t, 0), want: formatLDIF},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			header := test.header
+			if len(header) > formatHeaderSize {
+				header = header[:formatHeaderSize]
+			}
+			assert.Equal(t, test.want, detectFormat(header))
+		})
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	records, err := ParseRecords([]byte(ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := NewLDIF([]byte(ldifData + ldifData2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name  string
+		data  []byte
+		lists int
+	}{
+		{name: "zip", data: zipData(t, map[string]string{"bw.ldif": ldifData, "fi.ldif": ldifData2}), lists: 2},
+		{name: "gzip", data: gzipData(t, []byte(ldifData+ldifData2)), lists: 2},
+		{name: "gzipped zip", data: gzipData(t, zipData(t, map[string]string{"icao.ldif": ldifData + ldifData2})), lists: 2},
+		{name: "zip with cms", data: zipData(t, map[string]string{
+			"bw.ml":   string(records[2].Value(masterListContentAttr)),
+			"fi.ldif": ldifData2,
+		}), lists: 2},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			converter, err := NewLDIF(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, expected.ToPem(), converter.ToPem())
+			if assert.Len(t, converter.MasterLists(), test.lists) {
+				assert.Equal(t, "BW", converter.MasterLists()[0].Country)
+				assert.Equal(t, 1, converter.MasterLists()[1].Index)
+			}
+		})
+	}
+
+	converter, err := NewLDIF(records[2].Value(masterListContentAttr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, converter.ToX509(), 2)
+}
+
+func TestLoadZipOtherFiles(t *testing.T) {
+	data := zipData(t, map[string]string{
+		"README.txt": "ICAO PKD collection\n",
+		"SHA256SUMS": "3a7bd3e2360a3d29eea436fcfb7e44c735d117c42d1c1835420b6b9942dd4f1b  icao.ldif\n",
+		"icao.ldif":  ldifData,
+		"notes.ldif": "# the collection of FI\n\n" + ldifData2,
+		"empty.ldif": "",
+	})
+
+	converter, err := NewLDIF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, converter.MasterLists(), 2)
+	assert.Empty(t, converter.Diagnostics())
+}
+
+func TestLoadLimits(t *testing.T) {
+	data := []byte(ldifData)
+	for i := 0; i < maxArchiveDepth; i++ {
+		data = gzipData(t, data)
+	}
+
+	converter, err := NewLDIF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, converter.MasterLists(), 1)
+
+	_, err = NewLDIF(gzipData(t, data))
+	assert.ErrorIs(t, err, ErrContentTooLarge)
+
+	_, err = NewLDIF(zipData(t, map[string]string{"icao.zip": string(zipData(t, map[string]string{"icao.ldif": ldifData}))}),
+		WithMaxDecompressedSize(int64(len(ldifData))))
+	assert.ErrorIs(t, err, ErrContentTooLarge)
+
+	_, err = NewLDIF(gzipData(t, []byte(strings.Repeat(ldifData, 8))), WithMaxDecompressedSize(int64(len(ldifData))))
+	assert.ErrorIs(t, err, ErrContentTooLarge)
+}
+
+func gzipData(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// zipData creates an archive with the members sorted by name and a directory
+func zipData(t *testing.T, members map[string]string) []byte {
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	if _, err := w.Create("pkd/"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Write([]byte(members[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
@@ -3,15 +3,12 @@ package ldif
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/pem"
 	"fmt"
 	"io"
 	"os"
-	"regexp"
 	"time"
 
-	"cloud.google.com/go/storage"
 	"github.com/rarimo/certificate-transparency-go/x509"
 	"github.com/rarimo/ldif-sdk/utils"
 	"google.golang.org/api/option"
@@ -19,112 +16,173 @@ import (
 
 const (
 	downloadingTimeout = time.Second * 60
+
+	masterListContentAttr = "pkdMasterListContent"
 )
 
 type LDIF interface {
+	// ToX509 returns the certificates of all master lists in LDIF order, unless
+	// another order is set with WithSortOrder. ToPem and RawPubKeys follow it.
 	ToX509() []*x509.Certificate
 	ToPem() []string
+	// RawPubKeys returns unique public keys in the order of their first certificates
 	RawPubKeys() ([][]byte, error)
+	// MasterLists returns parsed CSCA master lists with their metadata in LDIF order
+	MasterLists() []MasterList
+	// Provenance returns all the master lists the certificate was found in,
+	// nil when the certificate is not from this LDIF
+	Provenance(cert *x509.Certificate) []Provenance
+	// DocumentSigners returns DSCs read from userCertificate;binary entries
+	DocumentSigners() []DocumentSigner
+	// RevocationLists returns CRLs read from certificateRevocationList;binary entries
+	RevocationLists() []RevocationList
+	// DeviationLists returns ICAO deviation lists read from pkdDeviationListContent entries
+	DeviationLists() []DeviationListEntry
+	// DeviationsFor looks up known deviations of the documents signed by the certificate
+	DeviationsFor(cert *x509.Certificate) ([]Deviation, error)
+	// Verifications returns per master list signature verification results,
+	// it is empty unless verification was enabled with options
+	Verifications() []Verification
+	// Diagnostics returns the entries skipped in lenient parsing mode
+	Diagnostics() []Diagnostic
+	// PKDVersion returns the version of the snapshot: the highest pkdVersion of
+	// its entries or the sequence number of ICAO file name, 0 when unknown
+	PKDVersion() int
+	// ValidAt returns the certificates valid at the instant, like the current
+	// time or the signing time of a document, in the order of ToX509
+	ValidAt(t time.Time) []*x509.Certificate
+	// ExpiringWithin returns the certificates valid at the instant that expire
+	// within the window after it, in the order of ToX509
+	ExpiringWithin(t time.Time, window time.Duration) []*x509.Certificate
 }
 
 type ldif struct {
-	certificates []*x509.Certificate
+	certificates    []*x509.Certificate
+	masterLists     []MasterList
+	provenance      provenanceIndex
+	documentSigners []DocumentSigner
+	revocationLists []RevocationList
+	deviationLists  []DeviationListEntry
+	verifications   []Verification
+	diagnostics     []Diagnostic
+	version         int
 }
 
-// FromS3Bucket creates new LDIF instance from ICAO list downloaded from remote S3 (like Google Storage or Amazon S3)
-func FromS3Bucket(ctx context.Context, bucketName string, fileName string) (LDIF, error) {
-	client, err := storage.NewClient(ctx, option.WithoutAuthentication())
-	if err != nil {
-		return nil, fmt.Errorf("creating new storage client: %w", err)
-	}
-	defer client.Close()
+// FromS3Bucket creates new LDIF instance from ICAO list downloaded from the public Google Cloud Storage bucket.
+// FromSource with S3Source or GCSSource should be used to access Amazon S3 or private buckets.
+func FromS3Bucket(ctx context.Context, bucketName string, fileName string, opts ...Option) (LDIF, error) {
+	src := NewGCSSource(bucketName, fileName, WithGCSClientOptions(option.WithoutAuthentication()))
+	return FromSource(ctx, src, opts...)
+}
 
-	ctx, cancel := context.WithTimeout(ctx, downloadingTimeout)
-	defer cancel()
+// FromFile creates new LDIF instance from file, reading it entry by entry
+func FromFile(filename string, opts ...Option) (LDIF, error) {
+	return FromFileContext(context.Background(), filename, opts...)
+}
 
-	objReader, err := client.Bucket(bucketName).Object(fileName).NewReader(ctx)
+// FromFileContext is FromFile that stops reading when the context is done
+func FromFileContext(ctx context.Context, filename string, opts ...Option) (LDIF, error) {
+	file, err := os.Open(filename)
 	if err != nil {
-		return nil, fmt.Errorf("reading content %s from %s: %w", bucketName, fileName, err)
+		return nil, fmt.Errorf("opening %s: %w", filename, err)
 	}
-	defer objReader.Close()
-
-	return FromReader(objReader)
-}
+	defer file.Close()
 
-// FromFile creates new LDIF instance from file
-func FromFile(filename string) (LDIF, error) {
-	rawData, err := os.ReadFile(filename)
+	l, err := FromReaderContext(ctx, file, append([]Option{WithFileName(filename)}, opts...)...)
 	if err != nil {
 		return nil, fmt.Errorf("reading %s: %w", filename, err)
 	}
 
-	return NewLDIF(rawData)
+	return l, nil
 }
 
-// FromReader creates new LDIF instance from file
-func FromReader(r io.Reader) (LDIF, error) {
-	buf := &bytes.Buffer{}
-	if _, err := buf.ReadFrom(r); err != nil {
-		return nil, fmt.Errorf("read to buffer from reader: %w", err)
+// FromReader creates new LDIF instance from reader. Raw LDIF, raw CMS master
+// list, gzip and ZIP content is detected automatically, LDIF members of ZIP are
+// merged. LDIF data is not buffered: records are parsed one by one and only the
+// parsed entries are kept. Raw CMS master lists and ZIP archives are read into
+// memory as a whole, so the memory is bounded by a single entry only for plain
+// and gzip-compressed LDIF.
+func FromReader(r io.Reader, opts ...Option) (LDIF, error) {
+	return FromReaderContext(context.Background(), r, opts...)
+}
+
+// FromReaderContext is FromReader that checks the context between the entries
+// and stops with its error when it is done
+func FromReaderContext(ctx context.Context, r io.Reader, opts ...Option) (LDIF, error) {
+	ld := newLoader(ctx, newConfig(opts))
+	ld.addFileName(ld.cfg.fileName)
+
+	if err := ld.load(countingReader{r: r, n: &ld.progress.BytesRead}); err != nil {
+		return nil, fmt.Errorf("converting raw content to x509: %w", err)
 	}
 
-	return NewLDIF(buf.Bytes())
+	l, err := ld.finish()
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
 }
 
 // NewLDIF creates new LDIF instance from raw bytes
-func NewLDIF(data []byte) (LDIF, error) {
-	certificates, err := ldifToX509(data)
-	if err != nil {
-		return nil, fmt.Errorf("converting raw content to x509: %w", err)
-	}
+func NewLDIF(data []byte, opts ...Option) (LDIF, error) {
+	return NewLDIFContext(context.Background(), data, opts...)
+}
 
-	return &ldif{
-		certificates: certificates,
-	}, nil
+// NewLDIFContext is NewLDIF that checks the context between the entries and
+// stops with its error when it is done
+func NewLDIFContext(ctx context.Context, data []byte, opts ...Option) (LDIF, error) {
+	return FromReaderContext(ctx, bytes.NewReader(data), opts...)
 }
 
-func ldifToX509(rawData []byte) ([]*x509.Certificate, error) {
-	rawLDIFData, err := ldifDecode(rawData)
+// FromMasterListFile creates new LDIF instance from a standalone CMS-signed master
+// list file (.ml), like the ones published by states outside of ICAO PKD
+func FromMasterListFile(filename string, opts ...Option) (LDIF, error) {
+	data, err := os.ReadFile(filename)
 	if err != nil {
-		return nil, fmt.Errorf("decode ldif data: %w", err)
+		return nil, fmt.Errorf("reading %s: %w", filename, err)
 	}
 
-	masterLists, err := ExtractMasterLists(rawLDIFData)
+	l, err := FromMasterListBytes(data, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("extract master lists: %w", err)
+		return nil, fmt.Errorf("loading %s: %w", filename, err)
 	}
 
-	certs := make([]*x509.Certificate, 0, len(masterLists))
-	for _, masterList := range masterLists {
-		mlCerts, err := masterList.ToX509()
-		if err != nil {
-			return nil, fmt.Errorf("extract x509 certificates from master list: %w", err)
-		}
-		certs = append(certs, mlCerts...)
+	return l, nil
+}
+
+// FromMasterListBytes creates new LDIF instance from a single DER-encoded
+// CMS-signed master list. The master list has no DN, so its country is taken
+// from the signer certificate.
+func FromMasterListBytes(data []byte, opts ...Option) (LDIF, error) {
+	ld := newLoader(context.Background(), newConfig(opts))
+
+	record := &Record{}
+	if err := ld.addMasterList(record, 0, ld.decodeMasterList(record, data)); err != nil {
+		return nil, fmt.Errorf("converting master list to x509: %w", err)
 	}
 
-	return certs, nil
-}
+	l, err := ld.finish()
+	if err != nil {
+		return nil, err
+	}
 
-func ldifDecode(ldifData []byte) ([][]byte, error) {
-	var (
-		re           = regexp.MustCompile(`(?s)pkdMasterListContent:: (.*?)\n\n`)
-		dirtyData    = re.FindAllSubmatch(ldifData, -1)
-		ldifRawData  = make([][]byte, len(dirtyData))
-		newLineBytes = []byte("\n ")
-	)
+	return l, nil
+}
 
-	for i, entry := range dirtyData {
-		dataB64 := bytes.ReplaceAll(entry[1], newLineBytes, nil)
-		ldifRawData[i] = make([]byte, base64.StdEncoding.DecodedLen(len(dataB64)))
+// FromCertificates creates new LDIF instance from the certificates, e.g. a
+// manual allowlist of CSCAs. It has no master lists, so Provenance of its
+// certificates is nil.
+func FromCertificates(certs []*x509.Certificate, opts ...Option) (LDIF, error) {
+	ld := newLoader(context.Background(), newConfig(opts))
+	ld.result.certificates = append(ld.result.certificates, certs...)
 
-		_, err := base64.StdEncoding.Decode(ldifRawData[i], dataB64)
-		if err != nil {
-			return nil, fmt.Errorf("decode MasterListContent: %w", err)
-		}
+	l, err := ld.finish()
+	if err != nil {
+		return nil, err
 	}
 
-	return ldifRawData, nil
+	return l, nil
 }
 
 func (l ldif) ToX509() []*x509.Certificate {
@@ -148,3 +206,56 @@ func (l ldif) ToPem() []string {
 func (l ldif) RawPubKeys() ([][]byte, error) {
 	return utils.ExtractPubKeys(l.certificates)
 }
+
+func (l ldif) MasterLists() []MasterList {
+	return l.masterLists
+}
+
+func (l ldif) Provenance(cert *x509.Certificate) []Provenance {
+	return l.provenance[Fingerprint(cert)]
+}
+
+func (l ldif) DocumentSigners() []DocumentSigner {
+	return l.documentSigners
+}
+
+func (l ldif) RevocationLists() []RevocationList {
+	return l.revocationLists
+}
+
+func (l ldif) DeviationLists() []DeviationListEntry {
+	return l.deviationLists
+}
+
+func (l ldif) DeviationsFor(cert *x509.Certificate) ([]Deviation, error) {
+	var deviations []Deviation
+	for _, entry := range l.deviationLists {
+		found, err := entry.List.Find(cert)
+		if err != nil {
+			return nil, fmt.Errorf("find deviations in %s: %w", entry.DN, err)
+		}
+		deviations = append(deviations, found...)
+	}
+
+	return deviations, nil
+}
+
+func (l ldif) Verifications() []Verification {
+	return l.verifications
+}
+
+func (l ldif) Diagnostics() []Diagnostic {
+	return l.diagnostics
+}
+
+func (l ldif) PKDVersion() int {
+	return l.version
+}
+
+func (l ldif) ValidAt(t time.Time) []*x509.Certificate {
+	return utils.ValidAt(l.certificates, t)
+}
+
+func (l ldif) ExpiringWithin(t time.Time, window time.Duration) []*x509.Certificate {
+	return utils.ExpiringWithin(l.certificates, t, window)
+}
@@ -485,6 +485,8 @@ func TestLDIFToPubKeys(t *testing.T) {
 		{"2_different_keys", ldifData, 2},
 		{"5_different_keys", ldifData + ldifData2, 5},
 		{"4_total_keys_with_4_unique", ldifData + ldifData, 2},
+		{"crlf_line_endings", strings.ReplaceAll(ldifData+ldifData2, "\n", "\r\n"), 5},
+		{"no_trailing_separator", ldifData + strings.TrimRight(ldifData2, "\n"), 5},
 	}
 
 	for _, test := range testCases {
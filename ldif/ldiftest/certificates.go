@@ -0,0 +1,202 @@
+package ldiftest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	_ "crypto/sha256" // for crypto.SHA256
+	_ "crypto/sha512" // for crypto.SHA384 and crypto.SHA512
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rarimo/certificate-transparency-go/asn1"
+	"github.com/rarimo/certificate-transparency-go/x509"
+	"github.com/rarimo/certificate-transparency-go/x509/pkix"
+)
+
+// Credential is a certificate along with its private key
+type Credential struct {
+	Certificate *x509.Certificate
+	Key         crypto.Signer
+}
+
+// createCertificate issues the certificate for the public key, signed by the
+// key of the parent. x509 can neither marshal nor sign with the keys on the
+// curves without named curve OID, so for such keys the certificate is made
+// with a placeholder key, then its public key and signature are replaced.
+func createCertificate(template, parent *x509.Certificate, pub crypto.PublicKey, key crypto.Signer) (*x509.Certificate, error) {
+	var (
+		der []byte
+		err error
+	)
+
+	if hasNamedCurve(pub) && hasNamedCurve(key.Public()) {
+		der, err = x509.CreateCertificate(rand.Reader, template, parent, pub, key)
+		if err != nil {
+			return nil, fmt.Errorf("create certificate: %w", err)
+		}
+	} else {
+		der, err = createWithPlaceholder(template, parent, pub, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil && !errors.As(err, &x509.NonFatalErrors{}) {
+		return nil, fmt.Errorf("parse created certificate: %w", err)
+	}
+
+	return cert, nil
+}
+
+func createWithPlaceholder(template, parent *x509.Certificate, pub crypto.PublicKey, key crypto.Signer) ([]byte, error) {
+	placeholder, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate placeholder key: %w", err)
+	}
+
+	var spki []byte
+	certPub := pub
+	if !hasNamedCurve(pub) {
+		if spki, err = marshalPublicKey(pub); err != nil {
+			return nil, err
+		}
+		certPub = placeholder.Public()
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, certPub, placeholder)
+	if err != nil {
+		return nil, fmt.Errorf("create certificate: %w", err)
+	}
+
+	return resign(der, spki, key)
+}
+
+// createCRL issues the CRL revoking the certificates, signed by the CSCA
+func createCRL(csca Credential, revoked []*x509.Certificate, thisUpdate, nextUpdate time.Time) ([]byte, error) {
+	revokedCerts := make([]pkix.RevokedCertificate, len(revoked))
+	for i, cert := range revoked {
+		revokedCerts[i] = pkix.RevokedCertificate{SerialNumber: cert.SerialNumber, RevocationTime: thisUpdate}
+	}
+
+	key := csca.Key
+	if !hasNamedCurve(key.Public()) {
+		placeholder, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generate placeholder key: %w", err)
+		}
+		key = placeholder
+	}
+
+	der, err := csca.Certificate.CreateCRL(rand.Reader, key, revokedCerts, thisUpdate, nextUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("create CRL: %w", err)
+	}
+
+	if key == csca.Key {
+		return der, nil
+	}
+
+	return resign(der, nil, csca.Key)
+}
+
+// resign signs the certificate or CRL with another key. The signature algorithm
+// is the first SEQUENCE of TBS structure in both of them, subject public key
+// info of the certificate is the fifth one. When spki is not nil, it replaces
+// the public key of the certificate.
+func resign(der, spki []byte, key crypto.Signer) ([]byte, error) {
+	signed, err := sequenceElements(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse signed structure: %w", err)
+	}
+	if len(signed) != 3 {
+		return nil, fmt.Errorf("signed structure has %d elements instead of 3", len(signed))
+	}
+
+	tbs, err := sequenceElements(signed[0].FullBytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse TBS structure: %w", err)
+	}
+
+	algorithm, hash, err := signatureAlgorithm(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	algorithmDER, err := asn1.Marshal(algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("marshal signature algorithm: %w", err)
+	}
+
+	var sequences int
+	for i, elem := range tbs {
+		if elem.Class != asn1.ClassUniversal || elem.Tag != asn1.TagSequence {
+			continue
+		}
+
+		sequences++
+		switch {
+		case sequences == 1:
+			tbs[i] = asn1.RawValue{FullBytes: algorithmDER}
+		case sequences == 5 && spki != nil:
+			tbs[i] = asn1.RawValue{FullBytes: spki}
+		}
+	}
+
+	tbsDER, err := marshalSequence(tbs)
+	if err != nil {
+		return nil, fmt.Errorf("marshal TBS structure: %w", err)
+	}
+
+	digest := hash.New()
+	digest.Write(tbsDER)
+
+	signature, err := key.Sign(rand.Reader, digest.Sum(nil), hash)
+	if err != nil {
+		return nil, fmt.Errorf("sign TBS structure: %w", err)
+	}
+
+	signatureDER, err := asn1.Marshal(asn1.BitString{Bytes: signature, BitLength: len(signature) * 8})
+	if err != nil {
+		return nil, fmt.Errorf("marshal signature: %w", err)
+	}
+
+	return marshalSequence([]asn1.RawValue{
+		{FullBytes: tbsDER},
+		{FullBytes: algorithmDER},
+		{FullBytes: signatureDER},
+	})
+}
+
+func sequenceElements(der []byte) ([]asn1.RawValue, error) {
+	var seq asn1.RawValue
+	if _, err := asn1.Unmarshal(der, &seq); err != nil {
+		return nil, err
+	}
+
+	var elems []asn1.RawValue
+	for rest := seq.Bytes; len(rest) != 0; {
+		var (
+			elem asn1.RawValue
+			err  error
+		)
+		if rest, err = asn1.Unmarshal(rest, &elem); err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+	}
+
+	return elems, nil
+}
+
+func marshalSequence(elems []asn1.RawValue) ([]byte, error) {
+	var content []byte
+	for _, elem := range elems {
+		content = append(content, elem.FullBytes...)
+	}
+
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: content})
+}
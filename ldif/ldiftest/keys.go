@@ -0,0 +1,249 @@
+package ldiftest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"fmt"
+	"math/big"
+
+	"github.com/github/smimesign/ietf-cms/oid"
+	"github.com/keybase/go-crypto/brainpool"
+	"github.com/rarimo/certificate-transparency-go/asn1"
+	"github.com/rarimo/certificate-transparency-go/x509"
+	"github.com/rarimo/certificate-transparency-go/x509/pkix"
+)
+
+// KeyType describes the algorithm and the size of generated keys
+type KeyType struct {
+	// RSABits is the size of RSA modulus, used when Curve is nil
+	RSABits int
+	// Curve is the curve of ECDSA keys
+	Curve elliptic.Curve
+}
+
+// Key types used by the issuing states
+var (
+	RSA2048 = RSA(2048)
+	RSA4096 = RSA(4096)
+	// RSA6144 keys have 768-byte modulus, such keys are skipped by RawPubKeys
+	RSA6144 = RSA(6144)
+
+	ECDSAP256 = ECDSA(elliptic.P256())
+	ECDSAP384 = ECDSA(elliptic.P384())
+	ECDSAP521 = ECDSA(elliptic.P521())
+
+	BrainpoolP256r1 = ECDSA(brainpool.P256r1())
+	BrainpoolP384r1 = ECDSA(brainpool.P384r1())
+	BrainpoolP512r1 = ECDSA(brainpool.P512r1())
+)
+
+// RSA returns RSA key type with the modulus of the given size in bits
+func RSA(bits int) KeyType {
+	return KeyType{RSABits: bits}
+}
+
+// ECDSA returns ECDSA key type on the curve. Curves without named curve OID,
+// like brainpool ones, are encoded with explicit parameters, as most issuing
+// states do.
+func ECDSA(curve elliptic.Curve) KeyType {
+	return KeyType{Curve: curve}
+}
+
+// String returns the name of the key type, e.g. RSA-2048 or ECDSA-P-256
+func (k KeyType) String() string {
+	if k.Curve != nil {
+		return "ECDSA-" + k.Curve.Params().Name
+	}
+
+	return fmt.Sprintf("RSA-%d", k.RSABits)
+}
+
+// GenerateKey generates new private key of the type
+func (k KeyType) GenerateKey() (crypto.Signer, error) {
+	if k.Curve != nil {
+		key, err := ecdsa.GenerateKey(k.Curve, rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generate %s key: %w", k, err)
+		}
+
+		return key, nil
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, k.RSABits)
+	if err != nil {
+		return nil, fmt.Errorf("generate %s key: %w", k, err)
+	}
+
+	return key, nil
+}
+
+var oidPublicKeyECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+
+// oidPrimeField is the field type of ECParameters, see RFC 3279
+var oidPrimeField = asn1.ObjectIdentifier{1, 2, 840, 10045, 1, 1}
+
+// brainpoolA holds the curve coefficient a of brainpool r1 curves, which is
+// not exposed by their implementation, see RFC 5639
+var brainpoolA = map[string]string{
+	"brainpoolP256r1": "7D5A0975FC2C3057EEF67530417AFFE7FB8055C126DC5C6CE94A4B44F330B5D9",
+	"brainpoolP384r1": "7BC382C63D8C150C3C72080ACE05AFA0C2BEA28E4FB22787139165EFBA91F90F8AA5814A503AD4EB04A8C7DD22CE2826",
+	"brainpoolP512r1": "7830A3318B603B89E2327145AC234CC594CBDD8D3DF91610A83441CAEA9863BC2DED5D5AA8253AA10A2EF1C98B9AC8B57F1117A72BF2C7B9E7C1AC4D77FC94CA",
+}
+
+// brainpoolB holds the curve coefficient b of brainpool r1 curves
+var brainpoolB = map[string]string{
+	"brainpoolP256r1": "26DC5C6CE94A4B44F330B5D9BBD77CBF958416295CF7E1CE6BCCDC18FF8C07B6",
+	"brainpoolP384r1": "04A8C7DD22CE28268B39B55416F0447C2FB77DE107DCD2A62E880EA53EEB62D57CB4390295DBC9943AB78696FA504C11",
+	"brainpoolP512r1": "3DF91610A83441CAEA9863BC2DED5D5AA8253AA10A2EF1C98B9AC8B57F1117A72BF2C7B9E7C1AC4D77FC94CADC083E67984050B75EBAE5DD2809BD638016F723",
+}
+
+type ecParameters struct {
+	Version int
+	FieldID struct {
+		FieldType asn1.ObjectIdentifier
+		Prime     *big.Int
+	}
+	Curve struct {
+		A []byte
+		B []byte
+	}
+	Base     []byte
+	Order    *big.Int
+	Cofactor *big.Int
+}
+
+type publicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// hasNamedCurve reports whether the public key can be marshalled by x509
+func hasNamedCurve(pub crypto.PublicKey) bool {
+	key, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return true
+	}
+
+	_, ok = x509.OIDFromNamedCurve(key.Curve)
+	return ok
+}
+
+// marshalPublicKey encodes the public key to DER subject public key info.
+// Keys on the curves without named curve OID are encoded with explicit
+// parameters.
+func marshalPublicKey(pub crypto.PublicKey) ([]byte, error) {
+	if hasNamedCurve(pub) {
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return nil, fmt.Errorf("marshal public key: %w", err)
+		}
+
+		return der, nil
+	}
+
+	key := pub.(*ecdsa.PublicKey)
+	curve := key.Curve.Params()
+
+	a, b, err := curveCoefficients(curve)
+	if err != nil {
+		return nil, err
+	}
+
+	var params ecParameters
+	params.Version = 1
+	params.FieldID.FieldType = oidPrimeField
+	params.FieldID.Prime = curve.P
+	params.Curve.A = fieldElement(curve, a)
+	params.Curve.B = fieldElement(curve, b)
+	params.Base = point(curve, curve.Gx, curve.Gy)
+	params.Order = curve.N
+	params.Cofactor = big.NewInt(1)
+
+	paramsDER, err := asn1.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("marshal curve parameters: %w", err)
+	}
+
+	keyBytes := point(curve, key.X, key.Y)
+	der, err := asn1.Marshal(publicKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPublicKeyECDSA,
+			Parameters: asn1.RawValue{FullBytes: paramsDER},
+		},
+		PublicKey: asn1.BitString{Bytes: keyBytes, BitLength: len(keyBytes) * 8},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal public key: %w", err)
+	}
+
+	return der, nil
+}
+
+// curveCoefficients returns a and b of the curve y² = x³ + ax + b
+func curveCoefficients(curve *elliptic.CurveParams) (*big.Int, *big.Int, error) {
+	if a, ok := brainpoolA[curve.Name]; ok {
+		a, _ := new(big.Int).SetString(a, 16)
+		b, _ := new(big.Int).SetString(brainpoolB[curve.Name], 16)
+		return a, b, nil
+	}
+
+	if curve.B == nil {
+		return nil, nil, fmt.Errorf("unknown parameters of curve %s", curve.Name)
+	}
+
+	// crypto/elliptic curves, including brainpool t1 ones, have a = -3
+	return new(big.Int).Sub(curve.P, big.NewInt(3)), curve.B, nil
+}
+
+func fieldElement(curve *elliptic.CurveParams, value *big.Int) []byte {
+	return value.FillBytes(make([]byte, (curve.BitSize+7)/8))
+}
+
+// point encodes the point in uncompressed form
+func point(curve *elliptic.CurveParams, x, y *big.Int) []byte {
+	return append(append([]byte{4}, fieldElement(curve, x)...), fieldElement(curve, y)...)
+}
+
+// subjectKeyID computes the key identifier as SHA-1 hash of the public key bit
+// string, see RFC 5280 4.2.1.2
+func subjectKeyID(pub crypto.PublicKey) ([]byte, error) {
+	der, err := marshalPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	var spki publicKeyInfo
+	if _, err = asn1.Unmarshal(der, &spki); err != nil {
+		return nil, fmt.Errorf("unmarshal public key: %w", err)
+	}
+
+	hash := sha1.Sum(spki.PublicKey.Bytes)
+	return hash[:], nil
+}
+
+// signatureAlgorithm returns the algorithm the key signs with: SHA-256 for RSA
+// and the digest matching the curve size for ECDSA
+func signatureAlgorithm(pub crypto.PublicKey) (pkix.AlgorithmIdentifier, crypto.Hash, error) {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return pkix.AlgorithmIdentifier{
+			Algorithm:  asn1.ObjectIdentifier(oid.SignatureAlgorithmSHA256WithRSA),
+			Parameters: asn1.NullRawValue,
+		}, crypto.SHA256, nil
+	case *ecdsa.PublicKey:
+		switch size := key.Curve.Params().BitSize; {
+		case size <= 256:
+			return pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier(oid.SignatureAlgorithmECDSAWithSHA256)}, crypto.SHA256, nil
+		case size <= 384:
+			return pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier(oid.SignatureAlgorithmECDSAWithSHA384)}, crypto.SHA384, nil
+		default:
+			return pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier(oid.SignatureAlgorithmECDSAWithSHA512)}, crypto.SHA512, nil
+		}
+	default:
+		return pkix.AlgorithmIdentifier{}, 0, fmt.Errorf("unsupported key %T", pub)
+	}
+}
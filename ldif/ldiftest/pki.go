@@ -0,0 +1,466 @@
+// Package ldiftest generates synthetic PKI of issuing states for tests: CSCA
+// roots with link certificates, Master List Signers, DSCs, CRLs, signed CSCA
+// master lists and ICAO PKD-style LDIF with all of them.
+package ldiftest
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+	"github.com/rarimo/certificate-transparency-go/x509/pkix"
+	"github.com/rarimo/ldif-sdk/ldif"
+)
+
+// cscaValidity is the validity period of CSCA certificates, CSCAs are replaced
+// every year, so that all of them are valid at the reference time
+const cscaValidity = 10 * 365 * 24 * time.Hour
+
+// Option configures generation of the PKI
+type Option func(*config)
+
+type config struct {
+	countries       []string
+	cscaKey         KeyType
+	signerKey       KeyType
+	dscKey          KeyType
+	generations     int
+	documentSigners int
+	revoked         int
+	pkdVersion      int
+	now             time.Time
+}
+
+// WithCountries sets the codes of generated countries, XA and XB by default
+func WithCountries(codes ...string) Option {
+	return func(c *config) {
+		c.countries = codes
+	}
+}
+
+// WithKeyType sets the key type of all the certificates, RSA2048 by default
+func WithKeyType(keyType KeyType) Option {
+	return func(c *config) {
+		c.cscaKey = keyType
+		c.signerKey = keyType
+		c.dscKey = keyType
+	}
+}
+
+// WithCSCAKeyType sets the key type of CSCA certificates
+func WithCSCAKeyType(keyType KeyType) Option {
+	return func(c *config) {
+		c.cscaKey = keyType
+	}
+}
+
+// WithMasterListSignerKeyType sets the key type of Master List Signer certificates
+func WithMasterListSignerKeyType(keyType KeyType) Option {
+	return func(c *config) {
+		c.signerKey = keyType
+	}
+}
+
+// WithDocumentSignerKeyType sets the key type of DSCs
+func WithDocumentSignerKeyType(keyType KeyType) Option {
+	return func(c *config) {
+		c.dscKey = keyType
+	}
+}
+
+// WithGenerations sets the number of CSCA roots of each country, every next
+// root is linked to the previous one with a link certificate. 1 by default.
+func WithGenerations(generations int) Option {
+	return func(c *config) {
+		c.generations = generations
+	}
+}
+
+// WithDocumentSigners sets the number of DSCs of each country, 1 by default
+func WithDocumentSigners(count int) Option {
+	return func(c *config) {
+		c.documentSigners = count
+	}
+}
+
+// WithRevokedDocumentSigners sets the number of DSCs of each country revoked
+// by its CRL, the first ones are revoked. None by default.
+func WithRevokedDocumentSigners(count int) Option {
+	return func(c *config) {
+		c.revoked = count
+	}
+}
+
+// WithPKDVersion sets pkdVersion of LDIF entries, 1 by default
+func WithPKDVersion(version int) Option {
+	return func(c *config) {
+		c.pkdVersion = version
+	}
+}
+
+// WithTime sets the reference time the validity periods, signing times and CRL
+// updates are based on, current time by default
+func WithTime(now time.Time) Option {
+	return func(c *config) {
+		c.now = now
+	}
+}
+
+// Country is the PKI of a single issuing state
+type Country struct {
+	Code string
+	// CSCAs are self-signed CSCA roots from the oldest to the newest one
+	CSCAs []Credential
+	// Links are link certificates, Links[i] certifies the key of CSCAs[i+1]
+	// and is signed by CSCAs[i]
+	Links []*x509.Certificate
+	// MasterListSigner is issued by the newest CSCA
+	MasterListSigner Credential
+	// DocumentSigners are issued by the newest CSCA
+	DocumentSigners []Credential
+	// Revoked are the document signers revoked by the CRL
+	Revoked []*x509.Certificate
+	// CRL is DER-encoded CRL issued by the newest CSCA
+	CRL []byte
+	// MasterList is CMS-encoded master list with the CSCAs and link
+	// certificates, signed by MasterListSigner
+	MasterList []byte
+}
+
+// CSCA returns the newest CSCA of the country
+func (c *Country) CSCA() Credential {
+	return c.CSCAs[len(c.CSCAs)-1]
+}
+
+// Certificates returns the certificates of the country master list: CSCAs
+// followed by link certificates
+func (c *Country) Certificates() []*x509.Certificate {
+	certs := make([]*x509.Certificate, 0, len(c.CSCAs)+len(c.Links))
+	for _, csca := range c.CSCAs {
+		certs = append(certs, csca.Certificate)
+	}
+
+	return append(certs, c.Links...)
+}
+
+// PKI is a set of synthetic countries
+type PKI struct {
+	Countries  []*Country
+	PKDVersion int
+}
+
+// New generates the PKI of every country
+func New(opts ...Option) (*PKI, error) {
+	cfg := config{
+		countries:       []string{"XA", "XB"},
+		cscaKey:         RSA2048,
+		signerKey:       RSA2048,
+		dscKey:          RSA2048,
+		generations:     1,
+		documentSigners: 1,
+		pkdVersion:      1,
+		now:             time.Now(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cfg.generations < 1 {
+		return nil, fmt.Errorf("at least one CSCA generation is required, got %d", cfg.generations)
+	}
+	if cfg.revoked > cfg.documentSigners {
+		return nil, fmt.Errorf("%d document signers can not be revoked out of %d", cfg.revoked, cfg.documentSigners)
+	}
+
+	pki := &PKI{PKDVersion: cfg.pkdVersion}
+	for _, code := range cfg.countries {
+		country, err := newCountry(code, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("generate country %s: %w", code, err)
+		}
+
+		pki.Countries = append(pki.Countries, country)
+	}
+
+	return pki, nil
+}
+
+// Country returns the country by its code, nil when it is not generated
+func (p *PKI) Country(code string) *Country {
+	for _, country := range p.Countries {
+		if country.Code == code {
+			return country
+		}
+	}
+
+	return nil
+}
+
+// Certificates returns the certificates of all the master lists in the order
+// of the countries
+func (p *PKI) Certificates() []*x509.Certificate {
+	var certs []*x509.Certificate
+	for _, country := range p.Countries {
+		certs = append(certs, country.Certificates()...)
+	}
+
+	return certs
+}
+
+// LDIF returns the complete LDIF with master lists, DSCs and CRLs
+func (p *PKI) LDIF() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := p.WriteLDIF(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// WriteLDIF writes the complete LDIF with master lists, DSCs and CRLs
+func (p *PKI) WriteLDIF(w io.Writer) error {
+	return p.write(w, true, true)
+}
+
+// WriteMasterLists writes LDIF with master lists only, like ICAO PKD CSCA
+// master list collection (icaopkd-002)
+func (p *PKI) WriteMasterLists(w io.Writer) error {
+	return p.write(w, true, false)
+}
+
+// WriteDocumentSigners writes LDIF with DSCs and CRLs only, like ICAO PKD
+// DSC/CRL collection (icaopkd-001)
+func (p *PKI) WriteDocumentSigners(w io.Writer) error {
+	return p.write(w, false, true)
+}
+
+func (p *PKI) write(w io.Writer, masterLists, documentSigners bool) error {
+	pw := ldif.NewPKDWriter(w)
+
+	for _, country := range p.Countries {
+		if masterLists {
+			if err := pw.WriteMasterList(ldif.MasterList{
+				Country:    country.Code,
+				PKDVersion: p.PKDVersion,
+				Signer:     country.MasterListSigner.Certificate,
+				Raw:        country.MasterList,
+			}); err != nil {
+				return err
+			}
+		}
+
+		if !documentSigners {
+			continue
+		}
+
+		for _, dsc := range country.DocumentSigners {
+			if err := pw.WriteDocumentSigner(ldif.DocumentSigner{
+				Country:     country.Code,
+				Certificate: dsc.Certificate,
+				PKDVersion:  p.PKDVersion,
+			}); err != nil {
+				return err
+			}
+		}
+
+		crl, err := x509.ParseCRL(country.CRL)
+		if err != nil {
+			return fmt.Errorf("parse CRL of %s: %w", country.Code, err)
+		}
+
+		if err = pw.WriteRevocationList(ldif.RevocationList{
+			Country:    country.Code,
+			CRL:        crl,
+			Raw:        country.CRL,
+			PKDVersion: p.PKDVersion,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return pw.Flush()
+}
+
+// issuer issues certificates of a single country with unique serial numbers
+type issuer struct {
+	country string
+	serial  int64
+}
+
+func newCountry(code string, cfg config) (*Country, error) {
+	var (
+		country = &Country{Code: code}
+		iss     = &issuer{country: code}
+	)
+
+	for i := 0; i < cfg.generations; i++ {
+		notBefore := cfg.now.Add(-time.Duration(cfg.generations-i) * 365 * 24 * time.Hour)
+
+		csca, err := iss.csca(cfg.cscaKey, i+1, notBefore)
+		if err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			link, err := iss.link(csca, country.CSCA())
+			if err != nil {
+				return nil, err
+			}
+			country.Links = append(country.Links, link)
+		}
+
+		country.CSCAs = append(country.CSCAs, csca)
+	}
+
+	var (
+		csca      = country.CSCA()
+		notBefore = cfg.now.Add(-24 * time.Hour)
+		notAfter  = cfg.now.Add(365 * 24 * time.Hour)
+		err       error
+	)
+
+	country.MasterListSigner, err = iss.leaf(cfg.signerKey, "Master List Signer "+code, notBefore, notAfter, csca)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < cfg.documentSigners; i++ {
+		dsc, err := iss.leaf(cfg.dscKey, "Document Signer "+code+" "+strconv.Itoa(i+1), notBefore, notAfter, csca)
+		if err != nil {
+			return nil, err
+		}
+
+		country.DocumentSigners = append(country.DocumentSigners, dsc)
+		if i < cfg.revoked {
+			country.Revoked = append(country.Revoked, dsc.Certificate)
+		}
+	}
+
+	if country.CRL, err = createCRL(csca, country.Revoked, cfg.now.Add(-time.Hour), cfg.now.Add(30*24*time.Hour)); err != nil {
+		return nil, err
+	}
+
+	signer := country.MasterListSigner
+	country.MasterList, err = ldif.SignMasterList(country.Certificates(), signer.Certificate, signer.Key,
+		ldif.WithSigningTime(cfg.now.Add(-time.Hour)), ldif.WithDigest(digestFor(signer.Key.Public())))
+	if err != nil {
+		return nil, fmt.Errorf("sign master list: %w", err)
+	}
+
+	return country, nil
+}
+
+func (i *issuer) name(commonName string) pkix.Name {
+	return pkix.Name{
+		Country:      []string{i.country},
+		Organization: []string{"Government of " + i.country},
+		CommonName:   commonName,
+	}
+}
+
+func (i *issuer) nextSerial() *big.Int {
+	i.serial++
+	return big.NewInt(i.serial)
+}
+
+// csca generates self-signed CSCA of the generation
+func (i *issuer) csca(keyType KeyType, generation int, notBefore time.Time) (Credential, error) {
+	key, err := keyType.GenerateKey()
+	if err != nil {
+		return Credential{}, err
+	}
+
+	template, err := i.cscaTemplate(key.Public(), generation, notBefore)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	cert, err := createCertificate(template, template, key.Public(), key)
+	if err != nil {
+		return Credential{}, fmt.Errorf("create CSCA: %w", err)
+	}
+
+	return Credential{Certificate: cert, Key: key}, nil
+}
+
+// link generates link certificate for the new CSCA signed by the previous one
+func (i *issuer) link(csca, previous Credential) (*x509.Certificate, error) {
+	cert := csca.Certificate
+
+	template, err := i.cscaTemplate(csca.Key.Public(), 0, cert.NotBefore)
+	if err != nil {
+		return nil, err
+	}
+	template.Subject = cert.Subject
+
+	link, err := createCertificate(template, previous.Certificate, csca.Key.Public(), previous.Key)
+	if err != nil {
+		return nil, fmt.Errorf("create link certificate: %w", err)
+	}
+
+	return link, nil
+}
+
+func (i *issuer) cscaTemplate(pub crypto.PublicKey, generation int, notBefore time.Time) (*x509.Certificate, error) {
+	ski, err := subjectKeyID(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	return &x509.Certificate{
+		SerialNumber:          i.nextSerial(),
+		Subject:               i.name("CSCA " + i.country + " " + strconv.Itoa(generation)),
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(cscaValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+		SubjectKeyId:          ski,
+	}, nil
+}
+
+// leaf generates end-entity certificate issued by the CSCA
+func (i *issuer) leaf(keyType KeyType, commonName string, notBefore, notAfter time.Time, csca Credential) (Credential, error) {
+	key, err := keyType.GenerateKey()
+	if err != nil {
+		return Credential{}, err
+	}
+
+	ski, err := subjectKeyID(key.Public())
+	if err != nil {
+		return Credential{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: i.nextSerial(),
+		Subject:      i.name(commonName),
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		SubjectKeyId: ski,
+	}
+
+	cert, err := createCertificate(template, csca.Certificate, key.Public(), csca.Key)
+	if err != nil {
+		return Credential{}, fmt.Errorf("create %s: %w", commonName, err)
+	}
+
+	return Credential{Certificate: cert, Key: key}, nil
+}
+
+// digestFor returns the digest matching the key size
+func digestFor(pub crypto.PublicKey) crypto.Hash {
+	if _, ok := pub.(*ecdsa.PublicKey); ok {
+		_, hash, _ := signatureAlgorithm(pub)
+		return hash
+	}
+
+	return crypto.SHA256
+}
@@ -0,0 +1,154 @@
+package ldiftest
+
+import (
+	"crypto/rsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/rarimo/ldif-sdk/ldif"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	pki, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := pki.LDIF()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := ldif.NewLDIF(data, ldif.WithStrictVerification(), ldif.WithTrustAnchors(pki.Certificates()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, l.MasterLists(), 2)
+	assert.Len(t, l.ToX509(), 2)
+	assert.Len(t, l.DocumentSigners(), 2)
+	assert.Len(t, l.RevocationLists(), 2)
+	assert.Equal(t, 1, l.PKDVersion())
+
+	for i, country := range []string{"XA", "XB"} {
+		assert.Equal(t, country, l.MasterLists()[i].Country)
+		assert.Equal(t, ldif.SignatureValid, l.Verifications()[i].Status)
+	}
+}
+
+func TestKeyTypes(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"ECDSA P-384":       {WithKeyType(ECDSAP384)},
+		"brainpoolP256r1":   {WithKeyType(BrainpoolP256r1)},
+		"brainpoolP384r1":   {WithKeyType(BrainpoolP384r1)},
+		"RSA and brainpool": {WithCSCAKeyType(RSA2048), WithMasterListSignerKeyType(BrainpoolP512r1), WithDocumentSignerKeyType(ECDSAP256)},
+		"brainpool and RSA": {WithKeyType(RSA2048), WithCSCAKeyType(BrainpoolP256r1)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+			opts = append(opts, WithCountries("XC"), WithGenerations(2), WithDocumentSigners(2),
+				WithRevokedDocumentSigners(1), WithPKDVersion(7), WithTime(now))
+
+			pki, err := New(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			country := pki.Country("XC")
+			if !assert.NotNil(t, country) {
+				return
+			}
+
+			assert.Len(t, country.CSCAs, 2)
+			if assert.Len(t, country.Links, 1) {
+				link := country.Links[0]
+				assert.NoError(t, link.CheckSignatureFrom(country.CSCAs[0].Certificate))
+				assert.Equal(t, country.CSCAs[1].Certificate.RawSubject, link.RawSubject)
+				assert.Equal(t, country.CSCAs[1].Certificate.RawSubjectPublicKeyInfo, link.RawSubjectPublicKeyInfo)
+			}
+			for _, dsc := range country.DocumentSigners {
+				assert.NoError(t, dsc.Certificate.CheckSignatureFrom(country.CSCA().Certificate))
+			}
+
+			data, err := pki.LDIF()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			l, err := ldif.NewLDIF(data, ldif.WithStrictVerification(), ldif.WithTrustAnchors(pki.Certificates()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, 7, l.PKDVersion())
+			assert.Len(t, l.ToX509(), 3)
+			assert.Len(t, l.DocumentSigners(), 2)
+
+			if assert.Len(t, l.RevocationLists(), 1) {
+				crl := l.RevocationLists()[0].CRL
+				assert.NoError(t, country.CSCA().Certificate.CheckCRLSignature(crl))
+				if assert.Len(t, crl.TBSCertList.RevokedCertificates, 1) {
+					assert.Equal(t, country.Revoked[0].SerialNumber, crl.TBSCertList.RevokedCertificates[0].SerialNumber)
+				}
+			}
+
+			ml := l.MasterLists()[0]
+			assert.True(t, now.Add(-time.Hour).Equal(ml.SigningTime))
+			if assert.NotNil(t, ml.Signer) {
+				assert.Equal(t, country.MasterListSigner.Certificate.Raw, ml.Signer.Raw)
+			}
+		})
+	}
+}
+
+func TestRSA6144(t *testing.T) {
+	if testing.Short() {
+		t.Skip("generation of 6144-bit RSA keys is slow")
+	}
+
+	pki, err := New(WithCountries("XD"), WithCSCAKeyType(RSA6144))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := pki.LDIF()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := ldif.NewLDIF(data, ldif.WithStrictVerification(), ldif.WithTrustAnchors(pki.Certificates()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certs := l.ToX509()
+	if assert.Len(t, certs, 1) {
+		assert.Len(t, certs[0].PublicKey.(*rsa.PublicKey).N.Bytes(), 768)
+	}
+
+	keys, err := l.RawPubKeys()
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestBrainpoolParameters(t *testing.T) {
+	for _, keyType := range []KeyType{BrainpoolP256r1, BrainpoolP384r1, BrainpoolP512r1} {
+		curve := keyType.Curve.Params()
+		a, b, err := curveCoefficients(curve)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// y² = x³ + ax + b must hold for the generator
+		x, y := curve.Gx, curve.Gy
+		left := new(big.Int).Exp(y, big.NewInt(2), curve.P)
+		right := new(big.Int).Exp(x, big.NewInt(3), curve.P)
+		right.Add(right, new(big.Int).Mul(a, x))
+		right.Add(right, b)
+		right.Mod(right, curve.P)
+
+		assert.Equal(t, 0, left.Cmp(right), curve.Name)
+	}
+}
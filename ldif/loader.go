@@ -0,0 +1,309 @@
+package ldif
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+)
+
+// loader accumulates LDIF entries while the records are read
+type loader struct {
+	ctx      context.Context
+	cfg      config
+	result   *ldif
+	progress Progress
+	// masterListEntries is the number of master list entries read so far,
+	// including the ones skipped in lenient mode
+	masterListEntries int
+	// workers limits the number of master lists decoded concurrently, nil
+	// for sequential loading
+	workers chan struct{}
+	pending []*pendingRecord
+	// decompressedBytes is the number of bytes read from all the decompressors
+	decompressedBytes int64
+}
+
+func newLoader(ctx context.Context, cfg config) *loader {
+	ld := &loader{
+		ctx: ctx,
+		cfg: cfg,
+		result: &ldif{
+			certificates: make([]*x509.Certificate, 0),
+			provenance:   make(provenanceIndex),
+		},
+	}
+
+	if cfg.workers > 1 {
+		ld.workers = make(chan struct{}, cfg.workers)
+	}
+
+	return ld
+}
+
+// loadLDIF reads all the records from the reader, in lenient mode malformed
+// records are reported and skipped. Cancellation is checked between records.
+// With several workers master lists are decoded concurrently, while the
+// records are still added in the order they were read.
+func (ld *loader) loadLDIF(r io.Reader) error {
+	parser := NewParser(r)
+
+	for {
+		if err := ld.ctx.Err(); err != nil {
+			return err
+		}
+
+		record, err := parser.Next()
+		if err != nil {
+			// the records read before are added first, as in sequential loading
+			if flushErr := ld.flush(0); flushErr != nil {
+				return flushErr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		var syntaxErr *SyntaxError
+		if ld.cfg.lenient && errors.As(err, &syntaxErr) {
+			ld.result.diagnostics = append(ld.result.diagnostics, Diagnostic{
+				Line:             syntaxErr.Line,
+				MasterListIndex:  -1,
+				CertificateIndex: -1,
+				Err:              syntaxErr.Err,
+			})
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("parse LDIF record: %w", err)
+		}
+
+		if err = ld.queue(record); err != nil {
+			return err
+		}
+	}
+}
+
+// fail returns the diagnostic as an error, unless loader is lenient
+func (ld *loader) fail(diagnostic Diagnostic) error {
+	if !ld.cfg.lenient {
+		return diagnostic
+	}
+
+	ld.result.diagnostics = append(ld.result.diagnostics, diagnostic)
+	return nil
+}
+
+// addRecord collects master list certificates, DSCs, CRLs and deviation lists
+// from the record. Master list entries of the record are decoded already.
+func (ld *loader) addRecord(record *Record, masterLists []decodedMasterList) error {
+	l := ld.result
+
+	version, err := recordVersion(record)
+	if err != nil {
+		if err = ld.fail(newDiagnostic(record, pkdVersionAttr, record.Value(pkdVersionAttr), err)); err != nil {
+			return err
+		}
+	}
+	ld.addVersion(version)
+
+	for _, decoded := range masterLists {
+		if err = ld.addMasterList(record, version, decoded); err != nil {
+			return err
+		}
+	}
+
+	for _, value := range record.Values(userCertificateAttr) {
+		signer, err := parseDocumentSigner(record.DN, value)
+		if err != nil {
+			if err = ld.fail(newDiagnostic(record, userCertificateAttr, value, err)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		signer.PKDVersion = version
+		l.documentSigners = append(l.documentSigners, signer)
+		ld.progress.Certificates++
+	}
+
+	for _, value := range record.Values(crlAttr) {
+		crl, err := parseRevocationList(record.DN, value)
+		if err != nil {
+			if err = ld.fail(newDiagnostic(record, crlAttr, value, err)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		crl.PKDVersion = version
+		l.revocationLists = append(l.revocationLists, crl)
+	}
+
+	for _, value := range record.Values(deviationListContentAttr) {
+		list, err := parseDeviationListEntry(record.DN, value)
+		if err != nil {
+			if err = ld.fail(newDiagnostic(record, deviationListContentAttr, value, err)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		list.PKDVersion = version
+		l.deviationLists = append(l.deviationLists, list)
+	}
+
+	return nil
+}
+
+// decodedMasterList is the master list entry decoded independently of the
+// loader state, so that the entries can be decoded concurrently
+type decodedMasterList struct {
+	index        int
+	content      []byte
+	masterList   MasterList
+	certErrs     []CertificateError
+	verification *Verification
+	err          error
+}
+
+// decodeMasterList parses the master list entry with its certificates and
+// checks its signature, when verify is set
+func decodeMasterList(index int, dn string, content []byte, verify bool) decodedMasterList {
+	decoded := decodedMasterList{index: index, content: content}
+
+	signedData, encapData, err := parseSignedData(content)
+	if err != nil {
+		decoded.err = fmt.Errorf("parse master list: %w", err)
+		return decoded
+	}
+
+	decoded.masterList, decoded.certErrs, err = newMasterList(index, dn, signedData, encapData)
+	if err != nil {
+		decoded.err = fmt.Errorf("parse master list: %w", err)
+		return decoded
+	}
+
+	if verify {
+		verification := verifyMasterListSignature(decoded.masterList, signedData, encapData)
+		decoded.verification = &verification
+	}
+
+	return decoded
+}
+
+// decodeMasterList decodes the master list entry of the record under the next index
+func (ld *loader) decodeMasterList(record *Record, content []byte) decodedMasterList {
+	index := ld.masterListEntries
+	ld.masterListEntries++
+
+	return decodeMasterList(index, record.DN, content, ld.cfg.verifySignatures)
+}
+
+// decodeMasterLists decodes all the master list entries of the record
+func (ld *loader) decodeMasterLists(record *Record) []decodedMasterList {
+	values := record.Values(masterListContentAttr)
+	if len(values) == 0 {
+		return nil
+	}
+
+	decoded := make([]decodedMasterList, len(values))
+	for i, content := range values {
+		decoded[i] = ld.decodeMasterList(record, content)
+	}
+
+	return decoded
+}
+
+func (ld *loader) addMasterList(record *Record, version int, decoded decodedMasterList) error {
+	l := ld.result
+
+	diagnostic := newDiagnostic(record, masterListContentAttr, decoded.content, decoded.err)
+	diagnostic.MasterListIndex = decoded.index
+
+	if decoded.err != nil {
+		return ld.fail(diagnostic)
+	}
+
+	for _, certErr := range decoded.certErrs {
+		diagnostic.CertificateIndex = certErr.Index
+		diagnostic.DER = certErr.DER
+		diagnostic.Err = certErr.Err
+
+		if err := ld.fail(diagnostic); err != nil {
+			return err
+		}
+	}
+
+	// skipped entries are not counted, so the index is the position in MasterLists
+	ml := decoded.masterList
+	ml.Index = len(l.masterLists)
+	ml.PKDVersion = version
+	ml.Raw = decoded.content
+	l.masterLists = append(l.masterLists, ml)
+	l.certificates = append(l.certificates, ml.Certificates...)
+	l.provenance.add(ml)
+
+	ld.progress.MasterLists++
+	ld.progress.Certificates += len(ml.Certificates)
+
+	if decoded.verification != nil {
+		verification := *decoded.verification
+		verification.Index = ml.Index
+		l.verifications = append(l.verifications, verification)
+	}
+
+	return nil
+}
+
+// addVersion raises the snapshot version to the version of the entry
+func (ld *loader) addVersion(version int) {
+	if version > ld.result.version {
+		ld.result.version = version
+	}
+}
+
+// addFileName raises the snapshot version to the sequence number of ICAO file name
+func (ld *loader) addFileName(name string) {
+	if version, ok := VersionFromFileName(name); ok {
+		ld.addVersion(version)
+	}
+}
+
+// trustedCSCAs returns the certificate sets the signer of the master list may
+// be issued by: the trust anchors or the certificates of the other lists
+func (ld *loader) trustedCSCAs(index int) [][]*x509.Certificate {
+	if ld.cfg.trustAnchors != nil {
+		return [][]*x509.Certificate{ld.cfg.trustAnchors}
+	}
+
+	var cscas [][]*x509.Certificate
+	for i, ml := range ld.result.masterLists {
+		if i != index {
+			cscas = append(cscas, ml.Certificates)
+		}
+	}
+
+	return cscas
+}
+
+// finish runs the checks that need all the entries to be loaded
+func (ld *loader) finish() (*ldif, error) {
+	l := ld.result
+
+	SortCertificates(l.certificates, ld.cfg.sortOrder)
+
+	for i := range l.verifications {
+		verifySignerChain(&l.verifications[i], ld.trustedCSCAs(l.verifications[i].Index)...)
+	}
+
+	if ld.cfg.strictVerification {
+		if err := verificationsError(l.verifications); err != nil {
+			return nil, fmt.Errorf("verify master lists: %w", err)
+		}
+	}
+
+	return l, nil
+}
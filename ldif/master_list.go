@@ -1,14 +1,22 @@
 package ldif
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/github/smimesign/ietf-cms/protocol"
 	"github.com/rarimo/certificate-transparency-go/asn1"
 	"github.com/rarimo/certificate-transparency-go/x509"
 )
 
+// OIDCSCAMasterList is the content type of CMS-encoded CSCA master lists
+var OIDCSCAMasterList = asn1.ObjectIdentifier{2, 23, 136, 1, 1, 2}
+
 // CSCAMasterList represents a master list of Country Signing Certificate
 // Authority (CSCA). See https://pkddownloadsg.icao.int/ for more info.
 type CSCAMasterList struct {
@@ -16,44 +24,238 @@ type CSCAMasterList struct {
 	CertList []asn1.RawValue `asn1:"set"`
 }
 
+// NewCSCAMasterList builds master list of version 0 with the certificates. The
+// certificates are ordered as DER requires for SET OF.
+func NewCSCAMasterList(certs []*x509.Certificate) CSCAMasterList {
+	list := CSCAMasterList{CertList: make([]asn1.RawValue, len(certs))}
+	for i, cert := range certs {
+		list.CertList[i] = asn1.RawValue{FullBytes: cert.Raw}
+	}
+
+	sort.Slice(list.CertList, func(i, j int) bool {
+		return bytes.Compare(list.CertList[i].FullBytes, list.CertList[j].FullBytes) < 0
+	})
+
+	return list
+}
+
+// Marshal encodes master list to DER
+func (ml CSCAMasterList) Marshal() ([]byte, error) {
+	der, err := asn1.Marshal(ml)
+	if err != nil {
+		return nil, fmt.Errorf("marshal ASN.1 master list: %w", err)
+	}
+
+	return der, nil
+}
+
+// MasterList is a CSCA master list read from LDIF along with its metadata
+type MasterList struct {
+	// Index is the index of the master list in LDIF.MasterLists
+	Index   int
+	DN      string
+	Country string
+	// PKDVersion is the pkdVersion of LDIF entry, 0 when absent
+	PKDVersion int
+	// Version is the version of CscaMasterList structure
+	Version int
+	// SigningTime is taken from the signed attributes, zero when absent
+	SigningTime time.Time
+	// Signer is the Master List Signer certificate, nil when it is not embedded
+	Signer       *x509.Certificate
+	List         CSCAMasterList
+	Certificates []*x509.Certificate
+	// Raw is DER-encoded CMS content of the master list
+	Raw []byte
+}
+
+// CertificateError describes a master list certificate that failed to parse
+type CertificateError struct {
+	Index int
+	DER   []byte
+	Err   error
+}
+
 // ExtractMasterLists extracts CSCA master lists from raw LDIF data
 func ExtractMasterLists(rawData [][]byte) ([]CSCAMasterList, error) {
-	mls := make([]CSCAMasterList, len(rawData))
+	return ExtractMasterListsContext(context.Background(), rawData, nil)
+}
+
+// ExtractMasterListsContext is ExtractMasterLists that checks the context
+// between the master lists and reports the progress after each of them, when
+// progress callback is not nil. Certificates are not parsed, so the number of
+// raw certificates is reported.
+func ExtractMasterListsContext(ctx context.Context, rawData [][]byte, progress func(Progress)) ([]CSCAMasterList, error) {
+	var (
+		mls    = make([]CSCAMasterList, len(rawData))
+		status Progress
+	)
+
 	for i, entry := range rawData {
-		ci, err := protocol.ParseContentInfo(entry)
-		if err != nil {
-			return nil, fmt.Errorf("parse content info: %w", err)
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
 
-		signedData, err := ci.SignedDataContent()
+		list, err := ParseMasterList(entry)
 		if err != nil {
-			return nil, fmt.Errorf("extract signed data content: %w", err)
+			return nil, err
 		}
 
-		encapData, err := signedData.EncapContentInfo.EContentValue()
-		if err != nil {
-			return nil, fmt.Errorf("parse encapsulated content: %w", err)
+		mls[i] = list
+
+		status.BytesRead += int64(len(entry))
+		status.MasterLists++
+		status.Certificates += len(list.CertList)
+		if progress != nil {
+			progress(status)
 		}
+	}
 
-		var list CSCAMasterList
-		_, err = asn1.Unmarshal(encapData, &list)
-		if err != nil {
-			return nil, fmt.Errorf("unmarshal ASN.1 master list: %w", err)
+	return mls, nil
+}
+
+// ExtractMasterListsConcurrent is ExtractMasterListsContext that parses the
+// master lists on up to workers goroutines. The master lists, progress reports
+// and the error are the same as of ExtractMasterListsContext.
+func ExtractMasterListsConcurrent(ctx context.Context, rawData [][]byte, workers int, progress func(Progress)) ([]CSCAMasterList, error) {
+	if workers < 2 {
+		return ExtractMasterListsContext(ctx, rawData, progress)
+	}
+
+	var (
+		mls    = make([]CSCAMasterList, len(rawData))
+		errs   = make([]error, len(rawData))
+		done   = make([]chan struct{}, len(rawData))
+		sem    = make(chan struct{}, workers)
+		stop   = make(chan struct{})
+		status Progress
+	)
+	defer close(stop)
+
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	go func() {
+		for i, entry := range rawData {
+			select {
+			case sem <- struct{}{}:
+			case <-stop:
+				return
+			}
+
+			go func(i int, entry []byte) {
+				mls[i], errs[i] = ParseMasterList(entry)
+				<-sem
+				close(done[i])
+			}(i, entry)
 		}
+	}()
 
-		mls[i] = list
+	// results are collected in order, so that the first error is the same
+	for i, entry := range rawData {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-done[i]:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+
+		status.BytesRead += int64(len(entry))
+		status.MasterLists++
+		status.Certificates += len(mls[i].CertList)
+		if progress != nil {
+			progress(status)
+		}
 	}
 
 	return mls, nil
 }
 
+// ParseMasterList parses a single CMS-encoded CSCA master list
+func ParseMasterList(rawData []byte) (CSCAMasterList, error) {
+	_, encapData, err := parseSignedData(rawData)
+	if err != nil {
+		return CSCAMasterList{}, err
+	}
+
+	return unmarshalMasterList(encapData)
+}
+
+func unmarshalMasterList(encapData []byte) (CSCAMasterList, error) {
+	var list CSCAMasterList
+	_, err := asn1.Unmarshal(encapData, &list)
+	if err != nil {
+		return CSCAMasterList{}, fmt.Errorf("unmarshal ASN.1 master list: %w", err)
+	}
+
+	return list, nil
+}
+
+// newMasterList builds master list with metadata from the signed data. Signer
+// and signing time are informational, so the failures to get them are ignored.
+// Certificates that fail to parse are skipped and returned separately.
+func newMasterList(index int, dn string, signedData *protocol.SignedData, encapData []byte) (MasterList, []CertificateError, error) {
+	list, err := unmarshalMasterList(encapData)
+	if err != nil {
+		return MasterList{}, nil, err
+	}
+
+	certs, certErrs := list.ToX509Lenient()
+
+	ml := MasterList{
+		Index:        index,
+		DN:           dn,
+		Country:      countryFromDN(dn),
+		Version:      list.Version,
+		List:         list,
+		Certificates: certs,
+	}
+
+	if len(signedData.SignerInfos) == 0 {
+		return ml, certErrs, nil
+	}
+
+	si := signedData.SignerInfos[0]
+	if signingTime, err := si.GetSigningTimeAttribute(); err == nil {
+		ml.SigningTime = signingTime
+	}
+
+	if embedded, err := embeddedCertificates(signedData); err == nil {
+		ml.Signer, _ = findSignerCertificate(si, embedded)
+	}
+
+	if ml.Country == "" && ml.Signer != nil && len(ml.Signer.Subject.Country) != 0 {
+		ml.Country = strings.ToUpper(ml.Signer.Subject.Country[0])
+	}
+
+	return ml, certErrs, nil
+}
+
+// parseCertificate parses DER certificate, ignoring x509.NonFatalErrors
+func parseCertificate(der []byte) (*x509.Certificate, error) {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil && !errors.As(err, &x509.NonFatalErrors{}) {
+		return nil, err
+	}
+
+	return cert, nil
+}
+
 // ToX509 converts to X.509 certificates, ignoring x509.NonFatalErrors
 func (ml CSCAMasterList) ToX509() ([]*x509.Certificate, error) {
 	certs := make([]*x509.Certificate, len(ml.CertList))
 
 	for i, derCertData := range ml.CertList {
-		cert, err := x509.ParseCertificate(derCertData.FullBytes)
-		if err != nil && !errors.As(err, &x509.NonFatalErrors{}) {
+		cert, err := parseCertificate(derCertData.FullBytes)
+		if err != nil {
 			return nil, fmt.Errorf("parse x509 certificate: %w", err)
 		}
 
@@ -62,3 +264,28 @@ func (ml CSCAMasterList) ToX509() ([]*x509.Certificate, error) {
 
 	return certs, nil
 }
+
+// ToX509Lenient converts to X.509 certificates like ToX509, but skips the
+// certificates that fail to parse and returns their errors instead
+func (ml CSCAMasterList) ToX509Lenient() ([]*x509.Certificate, []CertificateError) {
+	var (
+		certs    = make([]*x509.Certificate, 0, len(ml.CertList))
+		certErrs []CertificateError
+	)
+
+	for i, derCertData := range ml.CertList {
+		cert, err := parseCertificate(derCertData.FullBytes)
+		if err != nil {
+			certErrs = append(certErrs, CertificateError{
+				Index: i,
+				DER:   derCertData.FullBytes,
+				Err:   fmt.Errorf("parse x509 certificate: %w", err),
+			})
+			continue
+		}
+
+		certs = append(certs, cert)
+	}
+
+	return certs, certErrs
+}
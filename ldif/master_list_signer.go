@@ -0,0 +1,251 @@
+package ldif
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	stdpkix "crypto/x509/pkix"
+	stdasn1 "encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/github/smimesign/ietf-cms/oid"
+	"github.com/github/smimesign/ietf-cms/protocol"
+	"github.com/rarimo/certificate-transparency-go/x509"
+)
+
+// signatureAlgorithms maps the signer key type and digest to SignerInfo signatureAlgorithm
+var signatureAlgorithms = map[string]map[crypto.Hash]stdasn1.ObjectIdentifier{
+	"rsa": {
+		crypto.SHA256: oid.SignatureAlgorithmSHA256WithRSA,
+		crypto.SHA384: oid.SignatureAlgorithmSHA384WithRSA,
+		crypto.SHA512: oid.SignatureAlgorithmSHA512WithRSA,
+	},
+	"ecdsa": {
+		crypto.SHA256: oid.SignatureAlgorithmECDSAWithSHA256,
+		crypto.SHA384: oid.SignatureAlgorithmECDSAWithSHA384,
+		crypto.SHA512: oid.SignatureAlgorithmECDSAWithSHA512,
+	},
+}
+
+// SignOption configures signing of the master list
+type SignOption func(*signConfig)
+
+type signConfig struct {
+	digest       crypto.Hash
+	signingTime  time.Time
+	certificates []*x509.Certificate
+}
+
+// WithDigest sets the digest algorithm, SHA-256 is used by default
+func WithDigest(digest crypto.Hash) SignOption {
+	return func(c *signConfig) {
+		c.digest = digest
+	}
+}
+
+// WithSigningTime sets the signing time attribute, current time is used by default
+func WithSigningTime(signingTime time.Time) SignOption {
+	return func(c *signConfig) {
+		c.signingTime = signingTime
+	}
+}
+
+// WithEmbeddedCertificates embeds additional certificates into SignedData,
+// e.g. the CSCA that issued the signer certificate
+func WithEmbeddedCertificates(certs ...*x509.Certificate) SignOption {
+	return func(c *signConfig) {
+		c.certificates = append(c.certificates, certs...)
+	}
+}
+
+// SignMasterList builds CSCA master list from the certificates and signs it
+// with the Master List Signer key, producing DER-encoded CMS SignedData as in
+// ICAO Doc 9303 Part 12. The signer certificate is embedded into SignedData and
+// identified by its issuer and serial number. RSA (PKCS #1 v1.5) and ECDSA keys
+// are supported.
+func SignMasterList(certs []*x509.Certificate, signer *x509.Certificate, key crypto.Signer, opts ...SignOption) ([]byte, error) {
+	cfg := signConfig{digest: crypto.SHA256, signingTime: time.Now()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	content, err := NewCSCAMasterList(certs).Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	eci, err := protocol.NewEncapsulatedContentInfo(stdasn1.ObjectIdentifier(OIDCSCAMasterList), content)
+	if err != nil {
+		return nil, fmt.Errorf("create encapsulated content info: %w", err)
+	}
+
+	signedData, err := protocol.NewSignedData(eci)
+	if err != nil {
+		return nil, fmt.Errorf("create signed data: %w", err)
+	}
+
+	for _, cert := range append([]*x509.Certificate{signer}, cfg.certificates...) {
+		var raw stdasn1.RawValue
+		if _, err = stdasn1.Unmarshal(cert.Raw, &raw); err != nil {
+			return nil, fmt.Errorf("unmarshal embedded certificate: %w", err)
+		}
+		signedData.Certificates = append(signedData.Certificates, raw)
+	}
+
+	si, err := newSignerInfo(signer, key, cfg, content)
+	if err != nil {
+		return nil, err
+	}
+
+	signedData.DigestAlgorithms = append(signedData.DigestAlgorithms, si.DigestAlgorithm)
+	signedData.SignerInfos = append(signedData.SignerInfos, si)
+
+	der, err := signedData.ContentInfoDER()
+	if err != nil {
+		return nil, fmt.Errorf("marshal content info: %w", err)
+	}
+
+	return der, nil
+}
+
+func newSignerInfo(signer *x509.Certificate, key crypto.Signer, cfg signConfig, content []byte) (protocol.SignerInfo, error) {
+	keyType, err := signerKeyType(signer, key)
+	if err != nil {
+		return protocol.SignerInfo{}, err
+	}
+
+	signatureOID, ok := signatureAlgorithms[keyType][cfg.digest]
+	if !ok {
+		return protocol.SignerInfo{}, fmt.Errorf("unsupported digest %s for %s key", cfg.digest, keyType)
+	}
+
+	signatureAlgorithm := stdpkix.AlgorithmIdentifier{Algorithm: signatureOID}
+	if keyType == "rsa" {
+		signatureAlgorithm.Parameters = stdasn1.NullRawValue
+	}
+
+	sid, err := stdasn1.Marshal(protocol.IssuerAndSerialNumber{
+		Issuer:       stdasn1.RawValue{FullBytes: signer.RawIssuer},
+		SerialNumber: signer.SerialNumber,
+	})
+	if err != nil {
+		return protocol.SignerInfo{}, fmt.Errorf("marshal issuer and serial number: %w", err)
+	}
+
+	si := protocol.SignerInfo{
+		Version:            1,
+		SID:                stdasn1.RawValue{FullBytes: sid},
+		DigestAlgorithm:    stdpkix.AlgorithmIdentifier{Algorithm: oid.CryptoHashToDigestAlgorithm[cfg.digest]},
+		SignatureAlgorithm: signatureAlgorithm,
+	}
+
+	digest := cfg.digest.New()
+	digest.Write(content)
+
+	if si.SignedAttrs, err = signedAttributes(cfg, digest.Sum(nil)); err != nil {
+		return protocol.SignerInfo{}, err
+	}
+
+	signedMessage, err := si.SignedAttrs.MarshaledForSigning()
+	if err != nil {
+		return protocol.SignerInfo{}, fmt.Errorf("marshal signed attributes: %w", err)
+	}
+
+	digest = cfg.digest.New()
+	digest.Write(signedMessage)
+
+	if si.Signature, err = key.Sign(rand.Reader, digest.Sum(nil), cfg.digest); err != nil {
+		return protocol.SignerInfo{}, fmt.Errorf("sign master list: %w", err)
+	}
+
+	return si, nil
+}
+
+// signedAttributes returns content type, signing time and message digest
+// attributes sorted by their DER encodings, as DER requires for SET OF
+func signedAttributes(cfg signConfig, messageDigest []byte) (protocol.Attributes, error) {
+	values := []struct {
+		typ   stdasn1.ObjectIdentifier
+		value interface{}
+	}{
+		{typ: oid.AttributeContentType, value: stdasn1.ObjectIdentifier(OIDCSCAMasterList)},
+		{typ: oid.AttributeSigningTime, value: cfg.signingTime.UTC()},
+		{typ: oid.AttributeMessageDigest, value: messageDigest},
+	}
+
+	type encodedAttribute struct {
+		attr protocol.Attribute
+		der  []byte
+	}
+
+	encoded := make([]encodedAttribute, len(values))
+	for i, v := range values {
+		attr, err := protocol.NewAttribute(v.typ, v.value)
+		if err != nil {
+			return nil, fmt.Errorf("create signed attribute %s: %w", v.typ, err)
+		}
+
+		der, err := stdasn1.Marshal(attr)
+		if err != nil {
+			return nil, fmt.Errorf("marshal signed attribute %s: %w", v.typ, err)
+		}
+		encoded[i] = encodedAttribute{attr: attr, der: der}
+	}
+
+	sort.Slice(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i].der, encoded[j].der) < 0
+	})
+
+	attrs := make(protocol.Attributes, len(encoded))
+	for i, e := range encoded {
+		attrs[i] = e.attr
+	}
+
+	return attrs, nil
+}
+
+// signerKeyType checks that the key belongs to the signer certificate and returns its type
+func signerKeyType(signer *x509.Certificate, key crypto.Signer) (string, error) {
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		certPub, ok := signer.PublicKey.(*rsa.PublicKey)
+		if !ok || certPub.N.Cmp(pub.N) != 0 || certPub.E != pub.E {
+			return "", errors.New("key does not match signer certificate")
+		}
+
+		return "rsa", nil
+	case *ecdsa.PublicKey:
+		certPub, ok := signer.PublicKey.(*ecdsa.PublicKey)
+		if !ok || !sameCurve(certPub, pub) || certPub.X.Cmp(pub.X) != 0 || certPub.Y.Cmp(pub.Y) != 0 {
+			return "", errors.New("key does not match signer certificate")
+		}
+
+		return "ecdsa", nil
+	default:
+		return "", fmt.Errorf("unsupported signer key %T", pub)
+	}
+}
+
+// sameCurve compares curve parameters, as the curves with explicit parameters
+// parsed from certificates are not the same instances as named ones. B is not
+// compared, as it is not set for brainpool curves.
+func sameCurve(a, b *ecdsa.PublicKey) bool {
+	if a.Curve == b.Curve {
+		return true
+	}
+
+	pa, pb := a.Curve.Params(), b.Curve.Params()
+	for _, pair := range [][2]*big.Int{{pa.P, pb.P}, {pa.N, pb.N}, {pa.Gx, pb.Gx}, {pa.Gy, pb.Gy}} {
+		if pair[0].Cmp(pair[1]) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,127 @@
+package ldif
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+	"github.com/rarimo/certificate-transparency-go/x509/pkix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignMasterList(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		key    crypto.Signer
+		digest crypto.Hash
+	}{
+		"RSA":   {key: rsaKey, digest: crypto.SHA256},
+		"ECDSA": {key: ecKey, digest: crypto.SHA384},
+	} {
+		t.Run(name, func(t *testing.T) {
+			csca, signer := testMasterListSigner(t, tc.key)
+			signingTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+			der, err := SignMasterList([]*x509.Certificate{csca}, signer, tc.key,
+				WithDigest(tc.digest), WithSigningTime(signingTime))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lists, err := ExtractMasterLists([][]byte{der})
+			if err != nil {
+				t.Fatal(err)
+			}
+			certs, err := lists[0].ToX509()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if assert.Len(t, certs, 1) {
+				assert.Equal(t, csca.Raw, certs[0].Raw)
+			}
+
+			l, err := FromMasterListBytes(der, WithStrictVerification(), WithTrustAnchors([]*x509.Certificate{csca}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ml := l.MasterLists()[0]
+			assert.Equal(t, "XA", ml.Country)
+			assert.True(t, signingTime.Equal(ml.SigningTime))
+			assert.Equal(t, der, ml.Raw)
+			if assert.NotNil(t, ml.Signer) {
+				assert.Equal(t, signer.Raw, ml.Signer.Raw)
+			}
+			assert.Equal(t, SignatureValid, l.Verifications()[0].Status)
+		})
+	}
+}
+
+func TestSignMasterListKeyMismatch(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csca, signer := testMasterListSigner(t, key)
+	_, err = SignMasterList([]*x509.Certificate{csca}, signer, other)
+	assert.Error(t, err)
+}
+
+// testMasterListSigner creates self-signed CSCA and Master List Signer
+// certificate issued by it, both with the given key
+func testMasterListSigner(t *testing.T, key crypto.Signer) (*x509.Certificate, *x509.Certificate) {
+	cscaTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Country: []string{"XA"}, CommonName: "CSCA Test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	csca := createTestCertificate(t, cscaTemplate, cscaTemplate, key)
+
+	signerTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{Country: []string{"XA"}, CommonName: "Master List Signer Test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	return csca, createTestCertificate(t, signerTemplate, csca, key)
+}
+
+func createTestCertificate(t *testing.T, template, parent *x509.Certificate, key crypto.Signer) *x509.Certificate {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
@@ -0,0 +1,103 @@
+package ldif
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMasterLists(t *testing.T) {
+	converter, err := NewLDIF([]byte(ldifData + ldifData2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lists := converter.MasterLists()
+	if !assert.Len(t, lists, 2) {
+		return
+	}
+
+	var total int
+	for i, country := range []string{"BW", "FI"} {
+		ml := lists[i]
+		assert.Equal(t, i, ml.Index)
+		assert.Equal(t, country, ml.Country)
+		assert.Equal(t, 0, ml.Version)
+		assert.False(t, ml.SigningTime.IsZero())
+		if assert.NotNil(t, ml.Signer) {
+			assert.Equal(t, []string{country}, ml.Signer.Subject.Country)
+		}
+		assert.Len(t, ml.Certificates, len(ml.List.CertList))
+
+		total += len(ml.Certificates)
+	}
+
+	assert.Equal(t, "cn=CN\\=CSCA-BWA\\,OU\\=MNIGA-DIC\\,O\\=GOV\\,C\\=BW,o=ml,c=BW,dc=data,dc=download,dc=pkd,dc=icao,dc=int", lists[0].DN)
+	assert.Equal(t, len(converter.ToX509()), total)
+}
+
+func TestFromMasterListFile(t *testing.T) {
+	records, err := ParseRecords([]byte(ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "BW.ml")
+	if err = os.WriteFile(filename, records[2].Value(masterListContentAttr), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	converter, err := FromMasterListFile(filename, WithStrictVerification(), testTrustAnchors(t, []byte(ldifData)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := NewLDIF([]byte(ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, expected.ToPem(), converter.ToPem())
+
+	lists := converter.MasterLists()
+	if assert.Len(t, lists, 1) {
+		assert.Empty(t, lists[0].DN)
+		assert.Equal(t, "BW", lists[0].Country)
+	}
+	if assert.Len(t, converter.Verifications(), 1) {
+		assert.Equal(t, SignatureValid, converter.Verifications()[0].Status)
+	}
+
+	_, err = FromMasterListBytes([]byte(ldifData))
+	assert.Error(t, err)
+}
+
+func TestFromMasterListBER(t *testing.T) {
+	records, err := ParseRecords([]byte(ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der := records[2].Value(masterListContentAttr)
+	if !assert.Equal(t, []byte{0x30, 0x82}, der[:2]) {
+		return
+	}
+
+	// the same ContentInfo with indefinite length
+	ber := append([]byte{0x30, 0x80}, der[4:]...)
+	ber = append(ber, 0x00, 0x00)
+
+	fromDER, err := FromMasterListBytes(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromBER, err := FromMasterListBytes(ber)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, fromDER.ToPem(), fromBER.ToPem())
+}
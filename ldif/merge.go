@@ -0,0 +1,414 @@
+package ldif
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+	"github.com/rarimo/ldif-sdk/utils"
+)
+
+// ErrMergeConflict is returned by Merge when a conflict is found and its
+// policy is ConflictFail
+var ErrMergeConflict = errors.New("merge conflict")
+
+// ConflictPolicy tells how Merge resolves a conflict
+type ConflictPolicy int
+
+const (
+	// ConflictKeepAll keeps all the conflicting certificates, the conflict is
+	// only reported
+	ConflictKeepAll ConflictPolicy = iota
+	// ConflictPreferFirst keeps the certificates of the source that comes
+	// first in the merge order
+	ConflictPreferFirst
+	// ConflictPreferNewest keeps the newest certificates: the ones of the
+	// master list with the latest signing time or, for key conflicts, the ones
+	// with the latest NotBefore
+	ConflictPreferNewest
+	// ConflictFail makes Merge fail with ErrMergeConflict
+	ConflictFail
+)
+
+// ConflictKind is the kind of merge conflict
+type ConflictKind string
+
+const (
+	// ConflictKeySubject is the same public key found under different subjects
+	ConflictKeySubject ConflictKind = "key_subject"
+	// ConflictStaleList is the country master list that is older in one source
+	// than in another one
+	ConflictStaleList ConflictKind = "stale_list"
+)
+
+// MergeSource is a named LDIF taking part in the merge, like ICAO PKD
+// download, national master list or allowlist made with FromCertificates.
+// Names of the sources must be unique.
+type MergeSource struct {
+	Name string
+	LDIF LDIF
+}
+
+// Origin tells the source and the master list a merged certificate came from.
+// MasterListIndex is -1 for the certificates that are not from a master list.
+type Origin struct {
+	Source string
+	Provenance
+}
+
+// MergedCertificate is a unique certificate along with all its origins in the
+// merge order
+type MergedCertificate struct {
+	Certificate *x509.Certificate
+	Origins     []Origin
+}
+
+// MergeConflict describes a conflict found by Merge and how it was resolved
+type MergeConflict struct {
+	Kind ConflictKind
+	// Country is the country of the stale master lists
+	Country string
+	// SPKIFingerprint is the fingerprint of the key with different subjects
+	SPKIFingerprint string
+	// Sources are the names of the sources involved in the conflict
+	Sources []string
+	// Dropped are the fingerprints of the certificates dropped by the policy
+	Dropped []string
+}
+
+// Merged is the union of several sources' certificates
+type Merged struct {
+	// Certificates are unique by fingerprint, in the order they were first
+	// found in the sources
+	Certificates []MergedCertificate
+	Conflicts    []MergeConflict
+	origins      map[string]int
+}
+
+// MergeOption configures Merge
+type MergeOption func(*mergeConfig)
+
+type mergeConfig struct {
+	keyConflicts ConflictPolicy
+	staleLists   ConflictPolicy
+}
+
+// WithKeyConflictPolicy sets the policy for the same public key found under
+// different subjects, ConflictKeepAll by default
+func WithKeyConflictPolicy(policy ConflictPolicy) MergeOption {
+	return func(c *mergeConfig) {
+		c.keyConflicts = policy
+	}
+}
+
+// WithStaleListPolicy sets the policy for the country master list that is
+// older in one source than in another one, ConflictKeepAll by default. Lists
+// are compared by signing time.
+func WithStaleListPolicy(policy ConflictPolicy) MergeOption {
+	return func(c *mergeConfig) {
+		c.staleLists = policy
+	}
+}
+
+// Merge unions the certificates of the sources, deduplicating them by
+// fingerprint and keeping the origins of each one. Public keys are
+// deduplicated by Merged.RawPubKeys. Sources are merged in the given order,
+// which is their priority for ConflictPreferFirst.
+func Merge(sources []MergeSource, opts ...MergeOption) (*Merged, error) {
+	var cfg mergeConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	merged := &Merged{origins: make(map[string]int)}
+
+	excluded, err := merged.resolveStaleLists(sources, cfg.staleLists)
+	if err != nil {
+		return nil, err
+	}
+
+	// dropped holds the certificates all origins of which were excluded
+	dropped := make(map[string][]string)
+
+	for _, src := range sources {
+		for _, cert := range src.LDIF.ToX509() {
+			fingerprint := Fingerprint(cert)
+
+			origins, droppedBy := certificateOrigins(src, cert, excluded)
+			if len(origins) == 0 {
+				dropped[droppedBy] = append(dropped[droppedBy], fingerprint)
+				continue
+			}
+
+			i, ok := merged.origins[fingerprint]
+			if !ok {
+				i = len(merged.Certificates)
+				merged.origins[fingerprint] = i
+				merged.Certificates = append(merged.Certificates, MergedCertificate{Certificate: cert})
+			}
+			merged.Certificates[i].Origins = append(merged.Certificates[i].Origins, origins...)
+		}
+	}
+
+	for i, conflict := range merged.Conflicts {
+		for _, fingerprint := range dropped[conflict.Country] {
+			if _, ok := merged.origins[fingerprint]; !ok && !containsString(conflict.Dropped, fingerprint) {
+				merged.Conflicts[i].Dropped = append(merged.Conflicts[i].Dropped, fingerprint)
+			}
+		}
+	}
+
+	if err = merged.resolveKeyConflicts(cfg.keyConflicts); err != nil {
+		return nil, err
+	}
+
+	return merged, nil
+}
+
+// ToX509 returns the merged certificates
+func (m *Merged) ToX509() []*x509.Certificate {
+	certs := make([]*x509.Certificate, len(m.Certificates))
+	for i, merged := range m.Certificates {
+		certs[i] = merged.Certificate
+	}
+
+	return certs
+}
+
+// ToPem returns the merged certificates in PEM form
+func (m *Merged) ToPem() []string {
+	pems := make([]string, len(m.Certificates))
+	for i, merged := range m.Certificates {
+		pems[i] = string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: merged.Certificate.Raw,
+		}))
+	}
+
+	return pems
+}
+
+// RawPubKeys returns unique public keys of the merged certificates
+func (m *Merged) RawPubKeys() ([][]byte, error) {
+	return utils.ExtractPubKeys(m.ToX509())
+}
+
+// Origins returns the origins of the certificate, nil when it is not merged
+func (m *Merged) Origins(cert *x509.Certificate) []Origin {
+	i, ok := m.origins[Fingerprint(cert)]
+	if !ok {
+		return nil
+	}
+
+	return m.Certificates[i].Origins
+}
+
+// countryListTime is the signing time of the newest country master list in the source
+type countryListTime struct {
+	source      string
+	signingTime time.Time
+}
+
+// resolveStaleLists finds the countries with master lists of different
+// signing times and returns the sources, lists of which are excluded, by
+// country
+func (m *Merged) resolveStaleLists(sources []MergeSource, policy ConflictPolicy) (map[string]map[string]bool, error) {
+	var (
+		countries []string
+		lists     = make(map[string][]countryListTime)
+	)
+
+	for _, src := range sources {
+		var (
+			order  []string
+			newest = make(map[string]time.Time)
+		)
+		for _, ml := range src.LDIF.MasterLists() {
+			current, ok := newest[ml.Country]
+			if !ok {
+				order = append(order, ml.Country)
+			}
+			if !ok || ml.SigningTime.After(current) {
+				newest[ml.Country] = ml.SigningTime
+			}
+		}
+
+		for _, country := range order {
+			if _, ok := lists[country]; !ok {
+				countries = append(countries, country)
+			}
+			lists[country] = append(lists[country], countryListTime{source: src.Name, signingTime: newest[country]})
+		}
+	}
+
+	excluded := make(map[string]map[string]bool)
+	for _, country := range countries {
+		var (
+			times  = lists[country]
+			latest = times[0].signingTime
+			stale  bool
+		)
+		for _, t := range times[1:] {
+			stale = stale || !t.signingTime.Equal(times[0].signingTime)
+			if t.signingTime.After(latest) {
+				latest = t.signingTime
+			}
+		}
+		if !stale {
+			continue
+		}
+
+		conflict := MergeConflict{Kind: ConflictStaleList, Country: country}
+		for _, t := range times {
+			conflict.Sources = append(conflict.Sources, t.source)
+		}
+
+		if policy == ConflictFail {
+			return nil, fmt.Errorf("%w: master lists of %s differ in sources %v", ErrMergeConflict, country, conflict.Sources)
+		}
+
+		excluded[country] = make(map[string]bool)
+		for i, t := range times {
+			switch policy {
+			case ConflictPreferFirst:
+				excluded[country][t.source] = i > 0
+			case ConflictPreferNewest:
+				excluded[country][t.source] = t.signingTime.Before(latest)
+			}
+		}
+
+		m.Conflicts = append(m.Conflicts, conflict)
+	}
+
+	return excluded, nil
+}
+
+// certificateOrigins returns the origins of the certificate in the source that
+// are not excluded. When all of them are excluded, the country of the last one
+// is returned.
+func certificateOrigins(src MergeSource, cert *x509.Certificate, excluded map[string]map[string]bool) ([]Origin, string) {
+	provenance := src.LDIF.Provenance(cert)
+	if len(provenance) == 0 {
+		return []Origin{{Source: src.Name, Provenance: Provenance{MasterListIndex: -1}}}, ""
+	}
+
+	var (
+		origins   []Origin
+		droppedBy string
+	)
+	for _, p := range provenance {
+		if excluded[p.Country][src.Name] {
+			droppedBy = p.Country
+			continue
+		}
+		origins = append(origins, Origin{Source: src.Name, Provenance: p})
+	}
+
+	return origins, droppedBy
+}
+
+// resolveKeyConflicts finds the keys with different subjects and drops the
+// certificates according to the policy
+func (m *Merged) resolveKeyConflicts(policy ConflictPolicy) error {
+	var (
+		keys   []string
+		byKey  = make(map[string][]int)
+		remove = make(map[int]bool)
+	)
+
+	for i, merged := range m.Certificates {
+		spki := SPKIFingerprint(merged.Certificate)
+		if _, ok := byKey[spki]; !ok {
+			keys = append(keys, spki)
+		}
+		byKey[spki] = append(byKey[spki], i)
+	}
+
+	for _, spki := range keys {
+		indexes := byKey[spki]
+
+		var conflicting bool
+		for _, i := range indexes[1:] {
+			conflicting = conflicting || !bytes.Equal(m.Certificates[i].Certificate.RawSubject, m.Certificates[indexes[0]].Certificate.RawSubject)
+		}
+		if !conflicting {
+			continue
+		}
+
+		conflict := MergeConflict{Kind: ConflictKeySubject, SPKIFingerprint: spki}
+		for _, i := range indexes {
+			for _, origin := range m.Certificates[i].Origins {
+				if !containsString(conflict.Sources, origin.Source) {
+					conflict.Sources = append(conflict.Sources, origin.Source)
+				}
+			}
+		}
+
+		if policy == ConflictFail {
+			return fmt.Errorf("%w: key %s has different subjects in sources %v", ErrMergeConflict, spki, conflict.Sources)
+		}
+
+		if kept, ok := keptSubject(m.Certificates, indexes, policy); ok {
+			for _, i := range indexes {
+				cert := m.Certificates[i].Certificate
+				if !bytes.Equal(cert.RawSubject, kept) {
+					remove[i] = true
+					conflict.Dropped = append(conflict.Dropped, Fingerprint(cert))
+				}
+			}
+		}
+
+		m.Conflicts = append(m.Conflicts, conflict)
+	}
+
+	if len(remove) == 0 {
+		return nil
+	}
+
+	certs := m.Certificates[:0]
+	for i, merged := range m.Certificates {
+		if !remove[i] {
+			certs = append(certs, merged)
+		}
+	}
+	m.Certificates = certs
+
+	m.origins = make(map[string]int, len(m.Certificates))
+	for i, merged := range m.Certificates {
+		m.origins[Fingerprint(merged.Certificate)] = i
+	}
+
+	return nil
+}
+
+// keptSubject returns the subject the certificates of which are kept by the policy
+func keptSubject(certs []MergedCertificate, indexes []int, policy ConflictPolicy) ([]byte, bool) {
+	switch policy {
+	case ConflictPreferFirst:
+		// certificates are in the merge order already
+		return certs[indexes[0]].Certificate.RawSubject, true
+	case ConflictPreferNewest:
+		newest := certs[indexes[0]].Certificate
+		for _, i := range indexes[1:] {
+			if cert := certs[i].Certificate; cert.NotBefore.After(newest.NotBefore) {
+				newest = cert
+			}
+		}
+		return newest.RawSubject, true
+	default:
+		return nil, false
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
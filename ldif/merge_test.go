@@ -0,0 +1,203 @@
+package ldif_test
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+	"github.com/rarimo/certificate-transparency-go/x509/pkix"
+	"github.com/rarimo/ldif-sdk/ldif"
+	"github.com/rarimo/ldif-sdk/ldif/ldiftest"
+	"github.com/stretchr/testify/assert"
+)
+
+// mergeFixture is ICAO-like LDIF with XA and XB master lists and a newer
+// national XA master list without the oldest XA CSCA
+type mergeFixture struct {
+	pki      *ldiftest.PKI
+	icao     ldif.LDIF
+	national ldif.LDIF
+}
+
+func newMergeFixture(t *testing.T) mergeFixture {
+	now := time.Now()
+	pki, err := ldiftest.New(ldiftest.WithKeyType(ldiftest.ECDSAP256), ldiftest.WithGenerations(2), ldiftest.WithTime(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := pki.LDIF()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	icao, err := ldif.NewLDIF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xa := pki.Country("XA")
+	ml, err := ldif.SignMasterList([]*x509.Certificate{xa.CSCA().Certificate, xa.Links[0]},
+		xa.MasterListSigner.Certificate, xa.MasterListSigner.Key, ldif.WithSigningTime(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	national, err := ldif.FromMasterListBytes(ml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return mergeFixture{pki: pki, icao: icao, national: national}
+}
+
+func (f mergeFixture) sources(extra ...ldif.MergeSource) []ldif.MergeSource {
+	return append([]ldif.MergeSource{
+		{Name: "icao", LDIF: f.icao},
+		{Name: "national", LDIF: f.national},
+	}, extra...)
+}
+
+func TestMerge(t *testing.T) {
+	f := newMergeFixture(t)
+	xa, xb := f.pki.Country("XA"), f.pki.Country("XB")
+
+	allowlist, err := ldif.FromCertificates([]*x509.Certificate{xb.CSCA().Certificate})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := ldif.Merge(f.sources(ldif.MergeSource{Name: "allowlist", LDIF: allowlist}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2 CSCAs and link of each country
+	assert.Len(t, merged.ToX509(), 6)
+	assert.Len(t, merged.ToPem(), 6)
+
+	keys, err := merged.RawPubKeys()
+	assert.NoError(t, err)
+	assert.Len(t, keys, 4)
+
+	origins := merged.Origins(xb.CSCA().Certificate)
+	if assert.Len(t, origins, 2) {
+		assert.Equal(t, "icao", origins[0].Source)
+		assert.Equal(t, "XB", origins[0].Country)
+		assert.Equal(t, "allowlist", origins[1].Source)
+		assert.Equal(t, -1, origins[1].MasterListIndex)
+	}
+
+	assert.Len(t, merged.Origins(xa.CSCAs[0].Certificate), 1)
+	assert.Len(t, merged.Origins(xa.Links[0]), 2)
+
+	if assert.Len(t, merged.Conflicts, 1) {
+		conflict := merged.Conflicts[0]
+		assert.Equal(t, ldif.ConflictStaleList, conflict.Kind)
+		assert.Equal(t, "XA", conflict.Country)
+		assert.Equal(t, []string{"icao", "national"}, conflict.Sources)
+		assert.Empty(t, conflict.Dropped)
+	}
+}
+
+func TestMergeStaleListPolicy(t *testing.T) {
+	f := newMergeFixture(t)
+	xa := f.pki.Country("XA")
+
+	merged, err := ldif.Merge(f.sources(), ldif.WithStaleListPolicy(ldif.ConflictPreferNewest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, merged.ToX509(), 5)
+	assert.Nil(t, merged.Origins(xa.CSCAs[0].Certificate))
+	if assert.Len(t, merged.Conflicts, 1) {
+		assert.Equal(t, []string{ldif.Fingerprint(xa.CSCAs[0].Certificate)}, merged.Conflicts[0].Dropped)
+	}
+
+	for _, origin := range merged.Origins(xa.CSCA().Certificate) {
+		assert.Equal(t, "national", origin.Source)
+	}
+
+	merged, err = ldif.Merge(f.sources(), ldif.WithStaleListPolicy(ldif.ConflictPreferFirst))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, merged.ToX509(), 6)
+	for _, origin := range merged.Origins(xa.CSCA().Certificate) {
+		assert.Equal(t, "icao", origin.Source)
+	}
+
+	_, err = ldif.Merge(f.sources(), ldif.WithStaleListPolicy(ldif.ConflictFail))
+	assert.True(t, errors.Is(err, ldif.ErrMergeConflict))
+}
+
+func TestMergeKeyConflictPolicy(t *testing.T) {
+	f := newMergeFixture(t)
+	xa := f.pki.Country("XA")
+
+	// the newest XA key certified under another subject
+	template := &x509.Certificate{
+		SerialNumber:          xa.CSCA().Certificate.SerialNumber,
+		Subject:               pkix.Name{Country: []string{"XA"}, CommonName: "Rogue CSCA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	key := xa.CSCA().Key
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rogue, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allowlist, err := ldif.FromCertificates([]*x509.Certificate{rogue})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := f.sources(ldif.MergeSource{Name: "allowlist", LDIF: allowlist})
+
+	merged, err := ldif.Merge(sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, merged.ToX509(), 7)
+
+	var keyConflicts []ldif.MergeConflict
+	for _, conflict := range merged.Conflicts {
+		if conflict.Kind == ldif.ConflictKeySubject {
+			keyConflicts = append(keyConflicts, conflict)
+		}
+	}
+	if assert.Len(t, keyConflicts, 1) {
+		assert.Equal(t, ldif.SPKIFingerprint(rogue), keyConflicts[0].SPKIFingerprint)
+		assert.Equal(t, []string{"icao", "national", "allowlist"}, keyConflicts[0].Sources)
+	}
+
+	merged, err = ldif.Merge(sources, ldif.WithKeyConflictPolicy(ldif.ConflictPreferFirst))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, merged.ToX509(), 6)
+	assert.Nil(t, merged.Origins(rogue))
+
+	merged, err = ldif.Merge(sources, ldif.WithKeyConflictPolicy(ldif.ConflictPreferNewest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the newest CSCA and its link certificate are dropped
+	assert.Len(t, merged.ToX509(), 5)
+	assert.NotNil(t, merged.Origins(rogue))
+	assert.Nil(t, merged.Origins(xa.Links[0]))
+
+	_, err = ldif.Merge(sources, ldif.WithKeyConflictPolicy(ldif.ConflictFail))
+	assert.True(t, errors.Is(err, ldif.ErrMergeConflict))
+}
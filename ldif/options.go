@@ -0,0 +1,116 @@
+package ldif
+
+import "github.com/rarimo/certificate-transparency-go/x509"
+
+// Option configures loading of LDIF data
+type Option func(*config)
+
+type config struct {
+	verifySignatures   bool
+	strictVerification bool
+	lenient            bool
+	sortOrder          SortOrder
+	progress           func(Progress)
+	workers            int
+	trustAnchors       []*x509.Certificate
+	// maxDecompressedSize limits the content of gzip and ZIP
+	maxDecompressedSize int64
+	fileName            string
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{maxDecompressedSize: defaultMaxDecompressedSize}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return cfg
+}
+
+// WithSignatureVerification enables verification of the CMS signature of each
+// master list with its embedded signer certificate. The signer certificate is
+// checked to be issued by a CSCA of the issuing country. Results are available
+// with LDIF.Verifications.
+//
+// The CSCAs are taken from WithTrustAnchors. Without trust anchors they are
+// taken from the other master lists of the same input, so a list never vouches
+// for itself, but the input as a whole does: a tampered input with fake lists
+// vouching for each other passes. Set trust anchors obtained out of band to
+// detect that.
+func WithSignatureVerification() Option {
+	return func(c *config) {
+		c.verifySignatures = true
+	}
+}
+
+// WithStrictVerification enables signature verification and makes loading fail
+// when any master list does not pass it. It has the same limitation without
+// trust anchors as WithSignatureVerification.
+func WithStrictVerification() Option {
+	return func(c *config) {
+		c.verifySignatures = true
+		c.strictVerification = true
+	}
+}
+
+// WithTrustAnchors sets the CSCAs the master list signers must be issued by
+// during signature verification, instead of the CSCAs of the input itself. It
+// does not enable the verification.
+func WithTrustAnchors(cscas []*x509.Certificate) Option {
+	return func(c *config) {
+		c.trustAnchors = cscas
+	}
+}
+
+// WithLenientParsing makes loading skip the entries and certificates that fail
+// to parse instead of failing. Skipped entries are reported with LDIF.Diagnostics.
+func WithLenientParsing() Option {
+	return func(c *config) {
+		c.lenient = true
+	}
+}
+
+// WithProgress sets the callback that is called with the loading progress
+// after every entry. It is called from the loading goroutine, so it should
+// return quickly.
+func WithProgress(fn func(Progress)) Option {
+	return func(c *config) {
+		c.progress = fn
+	}
+}
+
+// WithWorkers makes loading decode master lists, parse their certificates and
+// verify their signatures on up to n goroutines. The result is the same as of
+// sequential loading, as the entries are still added in the order they were
+// read. Progress may report bytes read ahead of the added entries. Sequential
+// loading is used by default and when n is less than 2.
+func WithWorkers(n int) Option {
+	return func(c *config) {
+		c.workers = n
+	}
+}
+
+// WithMaxDecompressedSize limits the total size of the content decompressed
+// from gzip and ZIP, including nested archives, 1 GiB by default. Loading fails
+// with ErrContentTooLarge when the limit is exceeded.
+func WithMaxDecompressedSize(size int64) Option {
+	return func(c *config) {
+		c.maxDecompressedSize = size
+	}
+}
+
+// WithFileName sets the name of the loaded content, the sequence number of
+// ICAO PKD download name raises the snapshot version, see VersionFromFileName.
+// FromFile and FromSource with a NamedSource set it themselves.
+func WithFileName(name string) Option {
+	return func(c *config) {
+		c.fileName = name
+	}
+}
+
+// WithSortOrder sets the order of the certificates and public keys, see SortOrder
+func WithSortOrder(order SortOrder) Option {
+	return func(c *config) {
+		c.sortOrder = order
+	}
+}
@@ -0,0 +1,541 @@
+package ldif
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ChangeType is the value of the changetype field of LDIF change record
+type ChangeType string
+
+const (
+	// ChangeTypeNone marks content records, that simply describe an entry
+	ChangeTypeNone   ChangeType = ""
+	ChangeTypeAdd    ChangeType = "add"
+	ChangeTypeDelete ChangeType = "delete"
+	ChangeTypeModify ChangeType = "modify"
+	ChangeTypeModRDN ChangeType = "modrdn"
+	ChangeTypeModDN  ChangeType = "moddn"
+)
+
+// ModOp is the operation of a single modification inside of modify change record
+type ModOp string
+
+const (
+	ModOpAdd     ModOp = "add"
+	ModOpDelete  ModOp = "delete"
+	ModOpReplace ModOp = "replace"
+)
+
+// Attribute is an attribute value specification of LDIF record. Name keeps the
+// whole attribute description with options, e.g. userCertificate;binary.
+type Attribute struct {
+	Name string
+	// Value holds the decoded value, it is empty when the value is given by URL
+	Value []byte
+	// URL is set for the values referenced with `:<` syntax, the referenced
+	// content is never fetched by the parser
+	URL string
+}
+
+// Control is an LDAP control attached to a change record
+type Control struct {
+	OID         string
+	Criticality bool
+	Value       []byte
+}
+
+// Modification is a single mod-spec of modify change record
+type Modification struct {
+	Op        ModOp
+	Attribute string
+	Values    []Attribute
+}
+
+// Record is a single LDIF record: either a content record describing a directory
+// entry or a change record describing changes to it. See RFC 2849 for details.
+type Record struct {
+	DN         string
+	ChangeType ChangeType
+	Controls   []Control
+	// Attributes are filled for content records and for add change records
+	Attributes []Attribute
+	// Modifications are filled for modify change records
+	Modifications []Modification
+	// NewRDN, DeleteOldRDN and NewSuperior are filled for modrdn/moddn change records
+	NewRDN       string
+	DeleteOldRDN bool
+	NewSuperior  string
+	// Line is the number of the line where the record starts
+	Line int
+}
+
+// Values returns values of all the attributes with given name. Name comparison
+// is case-insensitive, as attribute descriptions are in LDAP.
+func (r Record) Values(name string) [][]byte {
+	var values [][]byte
+	for _, attr := range r.Attributes {
+		if strings.EqualFold(attr.Name, name) {
+			values = append(values, attr.Value)
+		}
+	}
+
+	return values
+}
+
+// Value returns the first value of the attribute with given name or nil
+func (r Record) Value(name string) []byte {
+	for _, attr := range r.Attributes {
+		if strings.EqualFold(attr.Name, name) {
+			return attr.Value
+		}
+	}
+
+	return nil
+}
+
+// SyntaxError describes a malformed LDIF record. Parser skips the whole
+// malformed record, so reading can be continued with the next one.
+type SyntaxError struct {
+	Line int
+	Err  error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+func syntaxErrorf(line int, format string, args ...interface{}) error {
+	return &SyntaxError{Line: line, Err: fmt.Errorf(format, args...)}
+}
+
+// Parser reads LDIF records one by one from the underlying reader. It handles
+// both LF and CRLF line endings, folded lines, comments, base64 and URL values.
+type Parser struct {
+	r *bufio.Reader
+	// line is the number of the last read physical line
+	line int
+	// peeked is the physical line read ahead while unfolding
+	peeked    []byte
+	peekedNum int
+	hasPeeked bool
+	started   bool
+}
+
+// NewParser creates new LDIF parser over the reader
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: bufio.NewReader(r)}
+}
+
+// ParseRecords parses all LDIF records from raw bytes
+func ParseRecords(data []byte) ([]Record, error) {
+	var (
+		parser  = NewParser(bytes.NewReader(data))
+		records []Record
+	)
+
+	for {
+		record, err := parser.Next()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, *record)
+	}
+}
+
+// Next parses the next record. It returns io.EOF when there are no more records.
+func (p *Parser) Next() (*Record, error) {
+	line, start, err := p.nextNonEmpty()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.started {
+		p.started = true
+		if name, _, _ := bytes.Cut(line, []byte(":")); strings.EqualFold(string(name), "version") {
+			if err = p.parseVersion(line, start); err != nil {
+				return nil, err
+			}
+
+			if line, start, err = p.nextNonEmpty(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	spec, err := parseLine(line, start)
+	if err == nil && (!strings.EqualFold(spec.Name, "dn") || spec.URL != "") {
+		err = syntaxErrorf(start, "record must start with dn, got %q", spec.Name)
+	}
+	if err != nil {
+		// skip the rest of the malformed record, so the next call starts with the next one
+		if _, skipErr := p.recordLines(); skipErr != nil {
+			return nil, skipErr
+		}
+		return nil, err
+	}
+
+	record := &Record{DN: string(spec.Value), Line: start}
+
+	lines, err := p.recordLines()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = parseRecordBody(record, lines); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+func (p *Parser) parseVersion(line []byte, start int) error {
+	spec, err := parseLine(line, start)
+	if err != nil {
+		return err
+	}
+
+	if version := strings.TrimSpace(string(spec.Value)); version != "1" {
+		return syntaxErrorf(start, "unsupported LDIF version %q", version)
+	}
+
+	return nil
+}
+
+type numberedLine struct {
+	data []byte
+	num  int
+}
+
+// recordLines reads logical lines until the end of the current record
+func (p *Parser) recordLines() ([]numberedLine, error) {
+	var lines []numberedLine
+
+	for {
+		line, num, err := p.logicalLine()
+		if errors.Is(err, io.EOF) {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 {
+			return lines, nil
+		}
+
+		lines = append(lines, numberedLine{data: line, num: num})
+	}
+}
+
+// nextNonEmpty skips record separators and returns the first line of the next record
+func (p *Parser) nextNonEmpty() ([]byte, int, error) {
+	for {
+		line, num, err := p.logicalLine()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if len(line) != 0 {
+			return line, num, nil
+		}
+	}
+}
+
+// logicalLine returns the next unfolded line with comments skipped. Empty line
+// means the record separator.
+func (p *Parser) logicalLine() ([]byte, int, error) {
+	for {
+		line, start, err := p.physicalLine()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		for {
+			next, num, err := p.physicalLine()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, 0, err
+			}
+
+			if len(next) == 0 || next[0] != ' ' {
+				p.peeked, p.peekedNum, p.hasPeeked = next, num, true
+				break
+			}
+
+			line = append(line, next[1:]...)
+		}
+
+		if len(line) > 0 && line[0] == '#' {
+			continue
+		}
+
+		return line, start, nil
+	}
+}
+
+// physicalLine reads a line without the line ending along with its number
+func (p *Parser) physicalLine() ([]byte, int, error) {
+	if p.hasPeeked {
+		p.hasPeeked = false
+		return p.peeked, p.peekedNum, nil
+	}
+
+	line, err := p.r.ReadBytes('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, 0, fmt.Errorf("read line %d: %w", p.line+1, err)
+	}
+	if len(line) == 0 && errors.Is(err, io.EOF) {
+		return nil, 0, io.EOF
+	}
+
+	p.line++
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	line = bytes.TrimSuffix(line, []byte("\r"))
+
+	return line, p.line, nil
+}
+
+func parseRecordBody(record *Record, lines []numberedLine) error {
+	i := 0
+	for ; i < len(lines); i++ {
+		spec, err := parseLine(lines[i].data, lines[i].num)
+		if err != nil {
+			return err
+		}
+
+		if !strings.EqualFold(spec.Name, "control") {
+			break
+		}
+
+		control, err := parseControl(spec, lines[i].num)
+		if err != nil {
+			return err
+		}
+		record.Controls = append(record.Controls, control)
+	}
+
+	if i < len(lines) {
+		spec, err := parseLine(lines[i].data, lines[i].num)
+		if err != nil {
+			return err
+		}
+
+		if strings.EqualFold(spec.Name, "changetype") {
+			record.ChangeType = ChangeType(strings.ToLower(strings.TrimSpace(string(spec.Value))))
+			i++
+		}
+	}
+
+	if len(record.Controls) != 0 && record.ChangeType == ChangeTypeNone {
+		return syntaxErrorf(record.Line, "controls are allowed only in change records")
+	}
+
+	lines = lines[i:]
+	switch record.ChangeType {
+	case ChangeTypeNone, ChangeTypeAdd:
+		return parseAttributes(record, lines)
+	case ChangeTypeDelete:
+		if len(lines) != 0 {
+			return syntaxErrorf(lines[0].num, "unexpected content in delete record")
+		}
+		return nil
+	case ChangeTypeModRDN, ChangeTypeModDN:
+		return parseModRDN(record, lines)
+	case ChangeTypeModify:
+		return parseModify(record, lines)
+	default:
+		return syntaxErrorf(record.Line, "unknown changetype %q", record.ChangeType)
+	}
+}
+
+func parseAttributes(record *Record, lines []numberedLine) error {
+	for _, line := range lines {
+		spec, err := parseLine(line.data, line.num)
+		if err != nil {
+			return err
+		}
+
+		record.Attributes = append(record.Attributes, spec)
+	}
+
+	if record.ChangeType == ChangeTypeAdd && len(record.Attributes) == 0 {
+		return syntaxErrorf(record.Line, "add record without attributes")
+	}
+
+	return nil
+}
+
+func parseModRDN(record *Record, lines []numberedLine) error {
+	if len(lines) < 2 || len(lines) > 3 {
+		return syntaxErrorf(record.Line, "malformed %s record", record.ChangeType)
+	}
+
+	fields := []string{"newrdn", "deleteoldrdn", "newsuperior"}
+	for i, line := range lines {
+		spec, err := parseLine(line.data, line.num)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(spec.Name, fields[i]) {
+			return syntaxErrorf(line.num, "expected %s, got %q", fields[i], spec.Name)
+		}
+
+		switch i {
+		case 0:
+			record.NewRDN = string(spec.Value)
+		case 1:
+			switch string(spec.Value) {
+			case "0":
+				record.DeleteOldRDN = false
+			case "1":
+				record.DeleteOldRDN = true
+			default:
+				return syntaxErrorf(line.num, "deleteoldrdn must be 0 or 1, got %q", spec.Value)
+			}
+		case 2:
+			record.NewSuperior = string(spec.Value)
+		}
+	}
+
+	return nil
+}
+
+func parseModify(record *Record, lines []numberedLine) error {
+	var current *Modification
+
+	for _, line := range lines {
+		if bytes.Equal(line.data, []byte("-")) {
+			if current == nil {
+				return syntaxErrorf(line.num, "unexpected mod-spec separator")
+			}
+
+			record.Modifications = append(record.Modifications, *current)
+			current = nil
+			continue
+		}
+
+		spec, err := parseLine(line.data, line.num)
+		if err != nil {
+			return err
+		}
+
+		if current == nil {
+			op := ModOp(strings.ToLower(spec.Name))
+			if op != ModOpAdd && op != ModOpDelete && op != ModOpReplace {
+				return syntaxErrorf(line.num, "unknown modify operation %q", spec.Name)
+			}
+
+			current = &Modification{Op: op, Attribute: string(spec.Value)}
+			continue
+		}
+
+		if !strings.EqualFold(spec.Name, current.Attribute) {
+			return syntaxErrorf(line.num, "attribute %q does not match mod-spec attribute %q",
+				spec.Name, current.Attribute)
+		}
+		current.Values = append(current.Values, spec)
+	}
+
+	if current != nil {
+		return syntaxErrorf(record.Line, "mod-spec is not terminated with '-'")
+	}
+
+	return nil
+}
+
+func parseControl(spec Attribute, num int) (Control, error) {
+	if spec.URL != "" {
+		return Control{}, syntaxErrorf(num, "control can not be given by URL")
+	}
+
+	var (
+		value                = strings.TrimSpace(string(spec.Value))
+		head, rest, hasValue = strings.Cut(value, ":")
+		fields               = strings.Fields(head)
+		control              Control
+	)
+
+	if len(fields) == 0 || len(fields) > 2 {
+		return Control{}, syntaxErrorf(num, "malformed control %q", value)
+	}
+
+	control.OID = fields[0]
+	if len(fields) == 2 {
+		criticality, err := strconv.ParseBool(fields[1])
+		if err != nil {
+			return Control{}, syntaxErrorf(num, "malformed control criticality %q", fields[1])
+		}
+		control.Criticality = criticality
+	}
+
+	if hasValue {
+		// value-spec goes right after the colon and keeps its own syntax
+		valueSpec, err := parseValue([]byte(rest), num)
+		if err != nil {
+			return Control{}, err
+		}
+		if valueSpec.URL != "" {
+			return Control{}, syntaxErrorf(num, "control value can not be given by URL")
+		}
+		control.Value = valueSpec.Value
+	}
+
+	return control, nil
+}
+
+// parseLine parses `name: value`, `name:: base64` and `name:< url` lines
+func parseLine(line []byte, num int) (Attribute, error) {
+	name, rest, ok := bytes.Cut(line, []byte(":"))
+	if !ok || len(name) == 0 {
+		return Attribute{}, syntaxErrorf(num, "missing attribute separator")
+	}
+
+	spec, err := parseValue(rest, num)
+	if err != nil {
+		return Attribute{}, fmt.Errorf("attribute %s: %w", name, err)
+	}
+	spec.Name = string(name)
+
+	return spec, nil
+}
+
+// parseValue parses the value-spec: the part of the line after the first colon
+func parseValue(rest []byte, num int) (Attribute, error) {
+	switch {
+	case len(rest) > 0 && rest[0] == ':':
+		encoded := bytes.TrimSpace(rest[1:])
+		value := make([]byte, base64.StdEncoding.DecodedLen(len(encoded)))
+
+		n, err := base64.StdEncoding.Decode(value, encoded)
+		if err != nil {
+			return Attribute{}, syntaxErrorf(num, "decode base64 value: %w", err)
+		}
+
+		return Attribute{Value: value[:n]}, nil
+	case len(rest) > 0 && rest[0] == '<':
+		url := strings.TrimSpace(string(rest[1:]))
+		if url == "" {
+			return Attribute{}, syntaxErrorf(num, "empty URL value")
+		}
+
+		return Attribute{URL: url}, nil
+	default:
+		return Attribute{Value: bytes.TrimLeft(rest, " ")}, nil
+	}
+}
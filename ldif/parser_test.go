@@ -0,0 +1,127 @@
+package ldif
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const changesLDIF = `version: 1
+# comment that is
+  folded
+dn: cn=Some One,dc=example,dc=com
+changetype: add
+objectClass: person
+cn: Some One
+description:: V2hhdCBhIGNhcmVmdWwgcmVhZGVyIHlvdSBhcmUh
+jpegPhoto:< file:///usr/local/directory/photos/someone.jpg
+
+dn: cn=Some One,dc=example,dc=com
+control: 1.2.840.113556.1.4.805 true
+changetype: delete
+
+dn: cn=Some One,dc=example,dc=com
+changetype: modrdn
+newrdn: cn=Another One
+deleteoldrdn: 1
+newsuperior: ou=People,dc=example,dc=com
+
+dn: cn=Another One,ou=People,dc=example,dc=com
+changetype: modify
+add: postaladdress
+postaladdress: 123 Anystreet $ Sunnyvale, CA $ 94086
+-
+delete: description
+-
+replace: telephonenumber
+telephonenumber: +1 408 555 1234
+telephonenumber: +1 408 555 5678
+-
+`
+
+func TestParseRecordsChanges(t *testing.T) {
+	records, err := ParseRecords([]byte(changesLDIF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("records count: want 4, got %d", len(records))
+	}
+
+	add := records[0]
+	assert.Equal(t, ChangeTypeAdd, add.ChangeType)
+	assert.Equal(t, "cn=Some One,dc=example,dc=com", add.DN)
+	assert.Equal(t, 4, add.Line)
+	assert.Equal(t, "What a careful reader you are!", string(add.Value("Description")))
+	assert.Equal(t, [][]byte{[]byte("person")}, add.Values("objectclass"))
+	assert.Equal(t, "file:///usr/local/directory/photos/someone.jpg", add.Attributes[3].URL)
+
+	del := records[1]
+	assert.Equal(t, ChangeTypeDelete, del.ChangeType)
+	assert.Equal(t, []Control{{OID: "1.2.840.113556.1.4.805", Criticality: true}}, del.Controls)
+
+	modrdn := records[2]
+	assert.Equal(t, ChangeTypeModRDN, modrdn.ChangeType)
+	assert.Equal(t, "cn=Another One", modrdn.NewRDN)
+	assert.True(t, modrdn.DeleteOldRDN)
+	assert.Equal(t, "ou=People,dc=example,dc=com", modrdn.NewSuperior)
+
+	modify := records[3]
+	assert.Equal(t, ChangeTypeModify, modify.ChangeType)
+	if assert.Len(t, modify.Modifications, 3) {
+		assert.Equal(t, ModOpAdd, modify.Modifications[0].Op)
+		assert.Equal(t, "description", modify.Modifications[1].Attribute)
+		assert.Empty(t, modify.Modifications[1].Values)
+		assert.Len(t, modify.Modifications[2].Values, 2)
+	}
+}
+
+func TestParseRecordsLineEndings(t *testing.T) {
+	const content = "dn: c=BW,dc=data\nobjectClass: top\ndescription: long\n  value\n\n\ndn: c=FI,dc=data\nc: FI"
+
+	testCases := []struct {
+		name string
+		data string
+	}{
+		{"lf", content},
+		{"crlf", strings.ReplaceAll(content, "\n", "\r\n")},
+		{"trailing_separators", content + "\n\n\n"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			records, err := ParseRecords([]byte(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 2 {
+				t.Fatalf("records count: want 2, got %d", len(records))
+			}
+
+			assert.Equal(t, "long value", string(records[0].Value("description")))
+			assert.Equal(t, "FI", string(records[1].Value("c")))
+		})
+	}
+}
+
+func TestParseRecordsErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		data string
+	}{
+		{"no_dn", "objectClass: top\n"},
+		{"bad_base64", "dn: c=BW\nc:: %%%\n"},
+		{"no_separator", "dn: c=BW\nobjectClass\n"},
+		{"unknown_changetype", "dn: c=BW\nchangetype: rename\n"},
+		{"unterminated_modify", "dn: c=BW\nchangetype: modify\nadd: c\nc: BW\n"},
+		{"wrong_version", "version: 2\ndn: c=BW\n"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseRecords([]byte(test.data))
+			assert.Error(t, err)
+		})
+	}
+}
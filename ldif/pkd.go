@@ -0,0 +1,100 @@
+package ldif
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+	"github.com/rarimo/certificate-transparency-go/x509/pkix"
+)
+
+const (
+	userCertificateAttr = "userCertificate;binary"
+	crlAttr             = "certificateRevocationList;binary"
+)
+
+// DocumentSigner is a Document Signer Certificate (DSC) from the ICAO PKD
+// DSC/CRL collection along with the country it belongs to
+type DocumentSigner struct {
+	Country     string
+	DN          string
+	Certificate *x509.Certificate
+	// PKDVersion is the pkdVersion of LDIF entry, 0 when absent
+	PKDVersion int
+}
+
+// RevocationList is a certificate revocation list published for the country
+type RevocationList struct {
+	Country string
+	DN      string
+	CRL     *pkix.CertificateList
+	// Raw is DER-encoded CRL
+	Raw []byte
+	// PKDVersion is the pkdVersion of LDIF entry, 0 when absent
+	PKDVersion int
+}
+
+func parseDocumentSigner(dn string, value []byte) (DocumentSigner, error) {
+	cert, err := parseCertificate(value)
+	if err != nil {
+		return DocumentSigner{}, fmt.Errorf("parse x509 certificate: %w", err)
+	}
+
+	return DocumentSigner{
+		Country:     countryFromDN(dn),
+		DN:          dn,
+		Certificate: cert,
+	}, nil
+}
+
+func parseRevocationList(dn string, value []byte) (RevocationList, error) {
+	crl, err := x509.ParseCRL(value)
+	if err != nil {
+		return RevocationList{}, fmt.Errorf("parse CRL: %w", err)
+	}
+
+	return RevocationList{
+		Country: countryFromDN(dn),
+		DN:      dn,
+		CRL:     crl,
+		Raw:     value,
+	}, nil
+}
+
+// countryFromDN returns the value of the country RDN of PKD entry DN, e.g. BW
+// for cn=...,o=ml,c=BW,dc=data,dc=download,dc=pkd,dc=icao,dc=int
+func countryFromDN(dn string) string {
+	for _, rdn := range splitEscaped(dn, ',') {
+		for _, ava := range splitEscaped(rdn, '+') {
+			name, value, ok := strings.Cut(ava, "=")
+			if ok && strings.EqualFold(strings.TrimSpace(name), "c") {
+				return strings.ToUpper(strings.TrimSpace(value))
+			}
+		}
+	}
+
+	return ""
+}
+
+// splitEscaped splits the string by separator that is not escaped with a backslash
+func splitEscaped(s string, sep byte) []string {
+	var (
+		parts   []string
+		start   int
+		escaped bool
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\':
+			escaped = true
+		case s[i] == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
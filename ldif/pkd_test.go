@@ -0,0 +1,111 @@
+package ldif
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+	"github.com/rarimo/certificate-transparency-go/x509/pkix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountryFromDN(t *testing.T) {
+	testCases := []struct {
+		dn   string
+		want string
+	}{
+		{"cn=CN\\=CSCA-BWA\\,OU\\=MNIGA-DIC\\,O\\=GOV\\,C\\=BW,o=ml,c=BW,dc=data,dc=download,dc=pkd,dc=icao,dc=int", "BW"},
+		{"cn=OU\\=Passport CA\\,C\\=NZ+sn=42E575AF,o=dsc,c=nz,dc=data", "NZ"},
+		{"o=crl, C=FI, dc=data", "FI"},
+		{"dc=data,dc=download", ""},
+	}
+
+	for _, test := range testCases {
+		assert.Equal(t, test.want, countryFromDN(test.dn), test.dn)
+	}
+}
+
+func TestDocumentSignersAndCRLs(t *testing.T) {
+	block, _ := pem.Decode([]byte(PEMCerts[0]))
+	crl := testCRL(t)
+
+	data := fmt.Sprintf(`dn: c=BW,dc=data,dc=download,dc=pkd,dc=icao,dc=int
+objectClass: top
+objectClass: country
+c: BW
+
+dn: cn=OU\=MNIGA-DIC\,O\=GOV\,C\=BW+sn=01,o=dsc,c=BW,dc=data,dc=download,dc=pkd,dc=icao,dc=int
+pkdVersion: 1150
+userCertificate;binary:: %s
+sn: 01
+objectClass: inetOrgPerson
+objectClass: pkdDownload
+
+dn: cn=O\=GOV\,C\=FI,o=crl,c=FI,dc=data,dc=download,dc=pkd,dc=icao,dc=int
+pkdVersion: 1150
+certificateRevocationList;binary:: %s
+objectClass: cRLDistributionPoint
+objectClass: pkdDownload
+`, base64.StdEncoding.EncodeToString(block.Bytes), base64.StdEncoding.EncodeToString(crl))
+
+	converter, err := NewLDIF([]byte(data + ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, converter.ToX509(), 2)
+
+	signers := converter.DocumentSigners()
+	if assert.Len(t, signers, 1) {
+		assert.Equal(t, "BW", signers[0].Country)
+		assert.Equal(t, block.Bytes, signers[0].Certificate.Raw)
+	}
+
+	crls := converter.RevocationLists()
+	if assert.Len(t, crls, 1) {
+		assert.Equal(t, "FI", crls[0].Country)
+		assert.Len(t, crls[0].CRL.TBSCertList.RevokedCertificates, 1)
+	}
+}
+
+func testCRL(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Country: []string{"FI"}, CommonName: "CSCA Test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked := []pkix.RevokedCertificate{{SerialNumber: big.NewInt(42), RevocationTime: time.Now()}}
+	crl, err := cert.CreateCRL(rand.Reader, key, revoked, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return crl
+}
@@ -0,0 +1,277 @@
+package ldif
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+	"github.com/rarimo/certificate-transparency-go/x509/pkix"
+)
+
+// pkdBaseDN is the DN all ICAO PKD download entries are placed under
+const pkdBaseDN = "dc=data,dc=download,dc=pkd,dc=icao,dc=int"
+
+// Organizations of the PKD entries inside of the country
+const (
+	pkdMasterLists     = "ml"
+	pkdDocumentSigners = "dsc"
+	pkdRevocationLists = "crl"
+)
+
+// ErrNotInMasterList is returned by WriteLDIF when there are certificates that
+// are not in any master list, e.g. the ones of FromCertificates. PKD-style LDIF
+// carries CSCAs in master lists only, so they can not be written.
+var ErrNotInMasterList = errors.New("certificate is not in any master list")
+
+// PKDWriter writes ICAO PKD-style LDIF: master lists, DSCs and CRLs are placed
+// under their country entries with the same DNs and attributes as ICAO PKD
+// downloads use. Entries with DN keep it, the DN is built for the others.
+type PKDWriter struct {
+	w       *Writer
+	entries map[string]struct{}
+}
+
+// NewPKDWriter creates new writer, Flush must be called after the entries are written
+func NewPKDWriter(w io.Writer) *PKDWriter {
+	return &PKDWriter{
+		w:       NewWriter(w),
+		entries: make(map[string]struct{}),
+	}
+}
+
+// WriteLDIF writes all the master lists, DSCs and CRLs of LDIF in ICAO PKD
+// style. It fails with ErrNotInMasterList before writing anything, when the
+// output would not give back the certificates of LDIF.
+func WriteLDIF(w io.Writer, l LDIF) error {
+	if err := checkMasterListCoverage(l); err != nil {
+		return err
+	}
+
+	pw := NewPKDWriter(w)
+
+	for _, ml := range l.MasterLists() {
+		if err := pw.WriteMasterList(ml); err != nil {
+			return err
+		}
+	}
+
+	for _, ds := range l.DocumentSigners() {
+		if err := pw.WriteDocumentSigner(ds); err != nil {
+			return err
+		}
+	}
+
+	for _, crl := range l.RevocationLists() {
+		if err := pw.WriteRevocationList(crl); err != nil {
+			return err
+		}
+	}
+
+	return pw.Flush()
+}
+
+// checkMasterListCoverage checks that every certificate of LDIF is in one of
+// its master lists
+func checkMasterListCoverage(l LDIF) error {
+	covered := make(map[string]struct{})
+	for _, ml := range l.MasterLists() {
+		for _, cert := range ml.Certificates {
+			covered[Fingerprint(cert)] = struct{}{}
+		}
+	}
+
+	var missing []*x509.Certificate
+	for _, cert := range l.ToX509() {
+		if _, ok := covered[Fingerprint(cert)]; !ok {
+			missing = append(missing, cert)
+		}
+	}
+
+	if len(missing) != 0 {
+		return fmt.Errorf("%w: %d certificates, the first is %s", ErrNotInMasterList, len(missing), missing[0].Subject)
+	}
+
+	return nil
+}
+
+// WriteMasterList writes pkdMasterList entry with the raw CMS content of the
+// master list. Country is taken from the signer when it is not set.
+func (w *PKDWriter) WriteMasterList(ml MasterList) error {
+	if len(ml.Raw) == 0 {
+		return fmt.Errorf("master list %d has no raw content", ml.Index)
+	}
+
+	var issuer string
+	if ml.Signer != nil {
+		issuer = ml.Signer.Issuer.String()
+	}
+
+	country := entryCountry(ml.DN, ml.Country, ml.Signer)
+	if issuer == "" {
+		issuer = "C=" + country
+	}
+
+	return w.writeEntry(country, pkdMasterLists, ml.DN, escapeDNValue(issuer), []Attribute{
+		{Name: "sn", Value: []byte("1")},
+		{Name: "cn", Value: []byte(issuer)},
+		{Name: "objectClass", Value: []byte("top")},
+		{Name: "objectClass", Value: []byte("person")},
+		{Name: "objectClass", Value: []byte("pkdMasterList")},
+		{Name: "objectClass", Value: []byte("pkdDownload")},
+		{Name: masterListContentAttr, Value: ml.Raw},
+	}, ml.PKDVersion)
+}
+
+// WriteDocumentSigner writes DSC entry. Country is taken from the certificate
+// subject when it is not set.
+func (w *PKDWriter) WriteDocumentSigner(ds DocumentSigner) error {
+	cert := ds.Certificate
+	if cert == nil {
+		return fmt.Errorf("document signer %s has no certificate", ds.DN)
+	}
+
+	var (
+		issuer = cert.Issuer.String()
+		serial = strings.ToUpper(cert.SerialNumber.Text(16))
+	)
+
+	return w.writeEntry(entryCountry(ds.DN, ds.Country, cert), pkdDocumentSigners, ds.DN,
+		escapeDNValue(issuer)+"+sn="+serial, []Attribute{
+			{Name: userCertificateAttr, Value: cert.Raw},
+			{Name: "sn", Value: []byte(serial)},
+			{Name: "cn", Value: []byte(issuer)},
+			{Name: "objectClass", Value: []byte("inetOrgPerson")},
+			{Name: "objectClass", Value: []byte("pkdDownload")},
+			{Name: "objectClass", Value: []byte("organizationalPerson")},
+			{Name: "objectClass", Value: []byte("top")},
+			{Name: "objectClass", Value: []byte("person")},
+		}, ds.PKDVersion)
+}
+
+// WriteRevocationList writes CRL entry. Country is taken from the CRL issuer
+// when it is not set.
+func (w *PKDWriter) WriteRevocationList(crl RevocationList) error {
+	if len(crl.Raw) == 0 || crl.CRL == nil {
+		return fmt.Errorf("revocation list %s has no raw content", crl.DN)
+	}
+
+	var issuer pkix.Name
+	issuer.FillFromRDNSequence(&crl.CRL.TBSCertList.Issuer)
+
+	country := crl.Country
+	if country == "" && len(issuer.Country) != 0 {
+		country = strings.ToUpper(issuer.Country[0])
+	}
+
+	return w.writeEntry(entryCountry(crl.DN, country, nil), pkdRevocationLists, crl.DN,
+		escapeDNValue(issuer.String()), []Attribute{
+			{Name: crlAttr, Value: crl.Raw},
+			{Name: "objectClass", Value: []byte("top")},
+			{Name: "objectClass", Value: []byte("cRLDistributionPoint")},
+			{Name: "objectClass", Value: []byte("pkdDownload")},
+		}, crl.PKDVersion)
+}
+
+// Flush writes the buffered data to the underlying writer
+func (w *PKDWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// writeEntry writes the entry along with its parent country and organization
+// entries, when they were not written before. DN is built from cn when it is
+// empty, sn is added to cn when such DN was already written.
+func (w *PKDWriter) writeEntry(country, org, dn, cn string, attrs []Attribute, version int) error {
+	if country == "" {
+		return fmt.Errorf("unknown country of %s entry %s", org, dn)
+	}
+
+	countryDN := "c=" + country + "," + pkdBaseDN
+	if err := w.writeParent(countryDN, Attribute{Name: "objectClass", Value: []byte("country")},
+		Attribute{Name: "c", Value: []byte(country)}); err != nil {
+		return err
+	}
+
+	orgDN := "o=" + org + "," + countryDN
+	if err := w.writeParent(orgDN, Attribute{Name: "objectClass", Value: []byte("organization")},
+		Attribute{Name: "o", Value: []byte(org)}); err != nil {
+		return err
+	}
+
+	if dn == "" {
+		dn = "cn=" + cn + "," + orgDN
+		for i := 2; w.isWritten(dn); i++ {
+			dn = "cn=" + cn + "+sn=" + strconv.Itoa(i) + "," + orgDN
+		}
+	}
+	if w.isWritten(dn) {
+		return fmt.Errorf("duplicate entry %s", dn)
+	}
+
+	if version > 0 {
+		attrs = append([]Attribute{{Name: pkdVersionAttr, Value: []byte(strconv.Itoa(version))}}, attrs...)
+	}
+
+	return w.write(&Record{DN: dn, Attributes: attrs})
+}
+
+func (w *PKDWriter) writeParent(dn string, attrs ...Attribute) error {
+	if w.isWritten(dn) {
+		return nil
+	}
+
+	return w.write(&Record{
+		DN:         dn,
+		Attributes: append([]Attribute{{Name: "objectClass", Value: []byte("top")}}, attrs...),
+	})
+}
+
+func (w *PKDWriter) write(record *Record) error {
+	if err := w.w.Write(record); err != nil {
+		return err
+	}
+
+	w.entries[strings.ToLower(record.DN)] = struct{}{}
+	return nil
+}
+
+func (w *PKDWriter) isWritten(dn string) bool {
+	_, ok := w.entries[strings.ToLower(dn)]
+	return ok
+}
+
+// entryCountry returns the country of the entry: the given one, the one from
+// DN or the one from the certificate subject
+func entryCountry(dn, country string, cert *x509.Certificate) string {
+	if country != "" {
+		return strings.ToUpper(country)
+	}
+	if country = countryFromDN(dn); country != "" {
+		return country
+	}
+	if cert != nil && len(cert.Subject.Country) != 0 {
+		return strings.ToUpper(cert.Subject.Country[0])
+	}
+
+	return ""
+}
+
+// escapeDNValue escapes the attribute value for DN as in RFC 4514. Equal signs
+// are escaped too, as ICAO PKD does.
+func escapeDNValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case strings.IndexByte(`,+"\<>;=`, c) >= 0,
+			(c == '#' || c == ' ') && i == 0,
+			c == ' ' && i == len(value)-1:
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
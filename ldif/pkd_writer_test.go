@@ -0,0 +1,132 @@
+package ldif
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteLDIF(t *testing.T) {
+	crl := testCRL(t)
+	dsc := pemCertDER(t, 0)
+
+	data := fmt.Sprintf(`dn: cn=OU\=MNIGA-DIC\,O\=GOV\,C\=BW+sn=01,o=dsc,c=BW,dc=data,dc=download,dc=pkd,dc=icao,dc=int
+pkdVersion: 1150
+userCertificate;binary:: %s
+
+dn: cn=O\=GOV\,C\=FI,o=crl,c=FI,dc=data,dc=download,dc=pkd,dc=icao,dc=int
+certificateRevocationList;binary:: %s
+
+`, base64.StdEncoding.EncodeToString(dsc), base64.StdEncoding.EncodeToString(crl))
+
+	expected, err := NewLDIF([]byte(data + ldifData + ldifData2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = WriteLDIF(&buf, expected); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := NewLDIF(buf.Bytes(), WithStrictVerification(), testTrustAnchors(t, []byte(ldifData+ldifData2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, expected.ToPem(), written.ToPem())
+	assert.Equal(t, expected.PKDVersion(), written.PKDVersion())
+	assert.Equal(t, expected.DocumentSigners(), written.DocumentSigners())
+	assert.Equal(t, expected.RevocationLists(), written.RevocationLists())
+	if assert.Len(t, written.MasterLists(), 2) {
+		for i, ml := range written.MasterLists() {
+			assert.Equal(t, expected.MasterLists()[i].DN, ml.DN)
+			assert.Equal(t, expected.MasterLists()[i].PKDVersion, ml.PKDVersion)
+		}
+	}
+}
+
+func TestWriteLDIFBuildsDN(t *testing.T) {
+	expected, err := NewLDIF([]byte(ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ml := expected.MasterLists()[0]
+
+	// standalone master list has no DN, so it is built from the signer
+	standalone, err := FromMasterListBytes(ml.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	pw := NewPKDWriter(&buf)
+	if err = pw.WriteMasterList(standalone.MasterLists()[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err = pw.WriteMasterList(standalone.MasterLists()[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err = pw.WriteDocumentSigner(DocumentSigner{Certificate: ml.Certificates[0]}); err != nil {
+		t.Fatal(err)
+	}
+	if err = pw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := NewLDIF(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lists := written.MasterLists()
+	if assert.Len(t, lists, 2) {
+		assert.Equal(t, ml.DN, lists[0].DN)
+		assert.Equal(t, "BW", lists[1].Country)
+		assert.NotEqual(t, lists[0].DN, lists[1].DN)
+	}
+
+	signers := written.DocumentSigners()
+	if assert.Len(t, signers, 1) {
+		assert.Equal(t, "BW", signers[0].Country)
+		assert.Equal(t, ml.Certificates[0].Raw, signers[0].Certificate.Raw)
+	}
+
+	assert.Error(t, pw.WriteMasterList(MasterList{}))
+}
+
+func TestWriteLDIFFromCertificates(t *testing.T) {
+	source, err := NewLDIF([]byte(ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allowlist, err := FromCertificates(source.ToX509())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the certificates would be lost, as there are no master lists
+	var buf bytes.Buffer
+	err = WriteLDIF(&buf, allowlist)
+	assert.ErrorIs(t, err, ErrNotInMasterList)
+	assert.Empty(t, buf.Bytes())
+
+	empty, err := FromCertificates(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = WriteLDIF(&buf, empty); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := NewLDIF(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, written.ToX509())
+}
@@ -0,0 +1,36 @@
+package ldif
+
+import (
+	"io"
+)
+
+// Progress describes how far loading has got, it is reported after every
+// loaded entry
+type Progress struct {
+	// BytesRead is the number of bytes read from the input, compressed input
+	// is counted before decompression
+	BytesRead int64
+	// MasterLists is the number of decoded master lists
+	MasterLists int
+	// Certificates is the number of parsed master list certificates and DSCs
+	Certificates int
+}
+
+// countingReader counts the bytes read from the underlying reader
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+// report calls the progress callback, if it is set
+func (ld *loader) report() {
+	if ld.cfg.progress != nil {
+		ld.cfg.progress(ld.progress)
+	}
+}
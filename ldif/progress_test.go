@@ -0,0 +1,91 @@
+package ldif
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress(t *testing.T) {
+	data := []byte(ldifData + ldifData2)
+
+	var reports []Progress
+	converter, err := NewLDIFContext(context.Background(), data, WithProgress(func(p Progress) {
+		reports = append(reports, p)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.NotEmpty(t, reports) {
+		return
+	}
+
+	for i := 1; i < len(reports); i++ {
+		assert.GreaterOrEqual(t, reports[i].BytesRead, reports[i-1].BytesRead)
+		assert.GreaterOrEqual(t, reports[i].MasterLists, reports[i-1].MasterLists)
+		assert.GreaterOrEqual(t, reports[i].Certificates, reports[i-1].Certificates)
+	}
+
+	last := reports[len(reports)-1]
+	assert.Equal(t, int64(len(data)), last.BytesRead)
+	assert.Equal(t, 2, last.MasterLists)
+	assert.Equal(t, len(converter.ToX509()), last.Certificates)
+}
+
+func TestCancellation(t *testing.T) {
+	data := []byte(ldifData + ldifData2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewLDIFContext(ctx, data)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	// cancel after the first master list is decoded
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var decoded int
+	_, err = NewLDIFContext(ctx, data, WithProgress(func(p Progress) {
+		decoded = p.MasterLists
+		if p.MasterLists == 1 {
+			cancel()
+		}
+	}))
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, 1, decoded)
+}
+
+func TestExtractMasterListsContext(t *testing.T) {
+	converter, err := NewLDIF([]byte(ldifData + ldifData2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw [][]byte
+	for _, ml := range converter.MasterLists() {
+		raw = append(raw, ml.Raw)
+	}
+
+	var last Progress
+	lists, err := ExtractMasterListsContext(context.Background(), raw, func(p Progress) {
+		last = p
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, lists, 2)
+	assert.Equal(t, int64(len(raw[0])+len(raw[1])), last.BytesRead)
+	assert.Equal(t, 2, last.MasterLists)
+	assert.Equal(t, len(lists[0].CertList)+len(lists[1].CertList), last.Certificates)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = ExtractMasterListsContext(ctx, raw, nil)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
@@ -0,0 +1,40 @@
+package ldif
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+)
+
+// Provenance describes a single master list a certificate was found in
+type Provenance struct {
+	// MasterListIndex is the index of the master list in LDIF.MasterLists
+	MasterListIndex int
+	// DN is the LDIF entry DN of the master list
+	DN      string
+	Country string
+	// Signer is the Master List Signer certificate
+	Signer *x509.Certificate
+}
+
+// Fingerprint returns hex-encoded SHA-256 hash of the DER certificate
+func Fingerprint(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(hash[:])
+}
+
+// provenanceIndex maps certificate fingerprints to the master lists they were found in
+type provenanceIndex map[string][]Provenance
+
+func (p provenanceIndex) add(ml MasterList) {
+	for _, cert := range ml.Certificates {
+		fingerprint := Fingerprint(cert)
+		p[fingerprint] = append(p[fingerprint], Provenance{
+			MasterListIndex: ml.Index,
+			DN:              ml.DN,
+			Country:         ml.Country,
+			Signer:          ml.Signer,
+		})
+	}
+}
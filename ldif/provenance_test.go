@@ -0,0 +1,43 @@
+package ldif
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvenance(t *testing.T) {
+	converter, err := NewLDIF([]byte(ldifData + ldifData2 + ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lists := converter.MasterLists()
+	for _, cert := range converter.ToX509() {
+		provenance := converter.Provenance(cert)
+		if !assert.NotEmpty(t, provenance) {
+			continue
+		}
+
+		for _, p := range provenance {
+			ml := lists[p.MasterListIndex]
+			assert.Equal(t, ml.DN, p.DN)
+			assert.Equal(t, ml.Country, p.Country)
+			assert.Equal(t, ml.Signer, p.Signer)
+			assert.Contains(t, ml.Certificates, cert)
+		}
+	}
+
+	// BW certificates are included twice, in the first and in the last master lists
+	bw := converter.Provenance(lists[0].Certificates[0])
+	if assert.Len(t, bw, 2) {
+		assert.Equal(t, 0, bw[0].MasterListIndex)
+		assert.Equal(t, 2, bw[1].MasterListIndex)
+	}
+
+	other, err := NewLDIF([]byte(ldifData2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, other.Provenance(lists[0].Certificates[0]))
+}
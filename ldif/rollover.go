@@ -0,0 +1,261 @@
+package ldif
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+)
+
+// CSCAKind tells whether a CSCA certificate is a root or a link certificate
+type CSCAKind string
+
+const (
+	// CSCARoot is a self-signed CSCA certificate
+	CSCARoot CSCAKind = "root"
+	// CSCALink is a CSCA certificate signed with another key, usually the key
+	// of the previous CSCA generation
+	CSCALink CSCAKind = "link"
+)
+
+// ClassifyCSCA tells whether the CSCA certificate is self-signed root or a
+// link certificate. Link certificates often keep the DN of the root, so the
+// certificate is a root only when its own key verifies its signature.
+func ClassifyCSCA(cert *x509.Certificate) CSCAKind {
+	if nameKey(cert.Subject) == nameKey(cert.Issuer) && issuedBy(cert, cert) {
+		return CSCARoot
+	}
+
+	return CSCALink
+}
+
+// CSCAGeneration is a CSCA key along with the certificates of the key
+type CSCAGeneration struct {
+	// SPKIFingerprint identifies the key of the generation, see SPKIFingerprint
+	SPKIFingerprint string
+	// Roots are self-signed certificates with the key
+	Roots []*x509.Certificate
+	// Links are link certificates with the key, signed by previous generations
+	Links []*x509.Certificate
+	// Previous are the indexes in RolloverGraph.Chain of the generations that
+	// signed the links
+	Previous []int
+}
+
+// RolloverGraph describes CSCA key rollovers of the country
+type RolloverGraph struct {
+	Country string
+	// Chain are the key generations from the oldest to the newest. Each
+	// generation comes after the ones that signed its links, independent
+	// generations are ordered by NotBefore of their certificates.
+	Chain []CSCAGeneration
+	// Orphans are link certificates that are not signed by any CSCA of the
+	// country. Their keys are in Chain only when there is a root or another
+	// link with the key.
+	Orphans []*x509.Certificate
+}
+
+// Latest returns the newest generation, nil when there are none
+func (g RolloverGraph) Latest() *CSCAGeneration {
+	if len(g.Chain) == 0 {
+		return nil
+	}
+
+	return &g.Chain[len(g.Chain)-1]
+}
+
+// NewRolloverGraphs builds the rollover graphs of all the countries of the
+// certificates, in the order of the first certificate of a country. Only CA
+// certificates are taken, DSCs and other certificates are skipped.
+func NewRolloverGraphs(certs []*x509.Certificate) []RolloverGraph {
+	var countries []string
+	seen := make(map[string]bool)
+	for _, cert := range certs {
+		if !isCSCA(cert) {
+			continue
+		}
+
+		for _, country := range certificateCountries(cert) {
+			if !seen[country] {
+				seen[country] = true
+				countries = append(countries, country)
+			}
+		}
+	}
+
+	store := NewCertStore(certs)
+	graphs := make([]RolloverGraph, len(countries))
+	for i, country := range countries {
+		graphs[i] = newRolloverGraph(store, country)
+	}
+
+	return graphs
+}
+
+// NewRolloverGraph builds the rollover graph of the country CSCAs, links are
+// verified with the certificates of the same country
+func NewRolloverGraph(certs []*x509.Certificate, country string) RolloverGraph {
+	return newRolloverGraph(NewCertStore(certs), country)
+}
+
+// rolloverNode is the generation being built
+type rolloverNode struct {
+	generation CSCAGeneration
+	notBefore  time.Time
+	// signers are the SPKI fingerprints of the generations that signed the links
+	signers []string
+}
+
+func newRolloverGraph(store *CertStore, country string) RolloverGraph {
+	graph := RolloverGraph{Country: strings.ToUpper(country)}
+
+	var (
+		keys  []string
+		nodes = make(map[string]*rolloverNode)
+	)
+	node := func(cert *x509.Certificate) *rolloverNode {
+		spki := SPKIFingerprint(cert)
+		n, ok := nodes[spki]
+		if !ok {
+			n = &rolloverNode{generation: CSCAGeneration{SPKIFingerprint: spki}, notBefore: cert.NotBefore}
+			nodes[spki] = n
+			keys = append(keys, spki)
+		}
+		if cert.NotBefore.Before(n.notBefore) {
+			n.notBefore = cert.NotBefore
+		}
+
+		return n
+	}
+
+	for _, cert := range store.ByCountry(graph.Country) {
+		if !isCSCA(cert) {
+			continue
+		}
+
+		if ClassifyCSCA(cert) == CSCARoot {
+			n := node(cert)
+			n.generation.Roots = append(n.generation.Roots, cert)
+			continue
+		}
+
+		signers := linkSigners(store, cert, graph.Country)
+		if len(signers) == 0 {
+			graph.Orphans = append(graph.Orphans, cert)
+			continue
+		}
+
+		n := node(cert)
+		n.generation.Links = append(n.generation.Links, cert)
+		for _, signer := range signers {
+			if !containsString(n.signers, signer) {
+				n.signers = append(n.signers, signer)
+			}
+		}
+	}
+
+	// orphan links may sign other links, but their keys are not generations
+	for _, n := range nodes {
+		signers := n.signers[:0]
+		for _, signer := range n.signers {
+			if _, ok := nodes[signer]; ok {
+				signers = append(signers, signer)
+			}
+		}
+		n.signers = signers
+	}
+
+	order := rolloverOrder(keys, nodes)
+	index := make(map[string]int, len(order))
+	for i, spki := range order {
+		index[spki] = i
+	}
+
+	for _, spki := range order {
+		n := nodes[spki]
+		for _, signer := range n.signers {
+			n.generation.Previous = append(n.generation.Previous, index[signer])
+		}
+		sort.Ints(n.generation.Previous)
+
+		graph.Chain = append(graph.Chain, n.generation)
+	}
+
+	return graph
+}
+
+// linkSigners returns the SPKI fingerprints of the country CSCAs that signed
+// the link certificate
+func linkSigners(store *CertStore, link *x509.Certificate, country string) []string {
+	var (
+		own     = SPKIFingerprint(link)
+		signers []string
+	)
+	for _, issuer := range store.Issuers(link) {
+		spki := SPKIFingerprint(issuer)
+		if spki == own || containsString(signers, spki) || !isCSCA(issuer) || !isCountryCertificate(issuer, country) {
+			continue
+		}
+
+		if issuedBy(link, issuer) {
+			signers = append(signers, spki)
+		}
+	}
+
+	return signers
+}
+
+// rolloverOrder sorts the generations topologically, so that signers come
+// first. Generations are taken by the earliest NotBefore, which also breaks
+// the cycles.
+func rolloverOrder(keys []string, nodes map[string]*rolloverNode) []string {
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := nodes[keys[i]], nodes[keys[j]]
+		if !a.notBefore.Equal(b.notBefore) {
+			return a.notBefore.Before(b.notBefore)
+		}
+		return keys[i] < keys[j]
+	})
+
+	var (
+		order []string
+		added = make(map[string]bool, len(keys))
+	)
+	for len(order) != len(keys) {
+		next := ""
+		for _, spki := range keys {
+			if added[spki] {
+				continue
+			}
+			if next == "" {
+				// fallback for a cycle
+				next = spki
+			}
+			if signersAdded(nodes[spki].signers, added) {
+				next = spki
+				break
+			}
+		}
+
+		added[next] = true
+		order = append(order, next)
+	}
+
+	return order
+}
+
+func signersAdded(signers []string, added map[string]bool) bool {
+	for _, signer := range signers {
+		if !added[signer] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isCSCA tells whether the certificate may issue certificates
+func isCSCA(cert *x509.Certificate) bool {
+	return cert.BasicConstraintsValid && cert.IsCA || cert.KeyUsage&x509.KeyUsageCertSign != 0
+}
@@ -0,0 +1,143 @@
+package ldif_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+	"github.com/rarimo/certificate-transparency-go/x509/pkix"
+	"github.com/rarimo/ldif-sdk/ldif"
+	"github.com/rarimo/ldif-sdk/ldif/ldiftest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRolloverGraphs(t *testing.T) {
+	pki, err := ldiftest.New(ldiftest.WithKeyType(ldiftest.ECDSAP256), ldiftest.WithGenerations(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := pki.LDIF()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := ldif.NewLDIF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xa := pki.Country("XA")
+	assert.Equal(t, ldif.CSCARoot, ldif.ClassifyCSCA(xa.CSCAs[0].Certificate))
+	assert.Equal(t, ldif.CSCALink, ldif.ClassifyCSCA(xa.Links[0]))
+
+	// DSCs are skipped
+	certs := append(l.ToX509(), xa.DocumentSigners[0].Certificate)
+
+	graphs := ldif.NewRolloverGraphs(certs)
+	if !assert.Len(t, graphs, 2) {
+		return
+	}
+
+	graph := graphs[0]
+	assert.Equal(t, "XA", graph.Country)
+	assert.Empty(t, graph.Orphans)
+	if !assert.Len(t, graph.Chain, 3) {
+		return
+	}
+
+	for i, generation := range graph.Chain {
+		csca := xa.CSCAs[i].Certificate
+		assert.Equal(t, ldif.SPKIFingerprint(csca), generation.SPKIFingerprint)
+		assert.Equal(t, []*x509.Certificate{csca}, generation.Roots)
+
+		if i == 0 {
+			assert.Empty(t, generation.Links)
+			assert.Empty(t, generation.Previous)
+			continue
+		}
+		assert.Equal(t, []*x509.Certificate{xa.Links[i-1]}, generation.Links)
+		assert.Equal(t, []int{i - 1}, generation.Previous)
+	}
+	assert.Equal(t, ldif.SPKIFingerprint(xa.CSCA().Certificate), graph.Latest().SPKIFingerprint)
+
+	assert.Equal(t, "XB", graphs[1].Country)
+	assert.Len(t, graphs[1].Chain, 3)
+}
+
+func TestRolloverGraphLinks(t *testing.T) {
+	root, rootKey := rolloverCertificate(t, "CSCA XC", nil, nil)
+	// the next generations keep the DN
+	next, nextKey := rolloverCertificate(t, "CSCA XC", nil, nil)
+	link, _ := rolloverCertificate(t, "CSCA XC", root, rootKey, nextKey)
+	// the link of the newest key comes first and has no root
+	newest, _ := rolloverCertificate(t, "CSCA XC", next, nextKey)
+
+	unknown, unknownKey := rolloverCertificate(t, "CSCA XC old", nil, nil)
+	orphan, _ := rolloverCertificate(t, "CSCA XC", unknown, unknownKey)
+
+	assert.Equal(t, ldif.CSCARoot, ldif.ClassifyCSCA(next))
+	assert.Equal(t, ldif.CSCALink, ldif.ClassifyCSCA(link))
+
+	graph := ldif.NewRolloverGraph([]*x509.Certificate{newest, orphan, next, link, root}, "xc")
+	assert.Equal(t, "XC", graph.Country)
+	assert.Equal(t, []*x509.Certificate{orphan}, graph.Orphans)
+
+	if !assert.Len(t, graph.Chain, 3) {
+		return
+	}
+
+	assert.Equal(t, []*x509.Certificate{root}, graph.Chain[0].Roots)
+	assert.Equal(t, []*x509.Certificate{next}, graph.Chain[1].Roots)
+	assert.Equal(t, []*x509.Certificate{link}, graph.Chain[1].Links)
+	assert.Equal(t, []int{0}, graph.Chain[1].Previous)
+	assert.Empty(t, graph.Chain[2].Roots)
+	assert.Equal(t, []*x509.Certificate{newest}, graph.Chain[2].Links)
+	assert.Equal(t, []int{1}, graph.Chain[2].Previous)
+}
+
+var rolloverSerial int64
+
+// rolloverCertificate creates CSCA of country XC, self-signed when issuer is
+// nil. The key is generated, unless it is given.
+func rolloverCertificate(t *testing.T, commonName string, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey, keys ...*ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	var key *ecdsa.PrivateKey
+	if len(keys) != 0 {
+		key = keys[0]
+	} else {
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rolloverSerial++
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(rolloverSerial),
+		Subject:               pkix.Name{Country: []string{"XC"}, CommonName: commonName},
+		NotBefore:             time.Now().Add(time.Duration(rolloverSerial) * time.Hour),
+		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if issuer == nil {
+		issuer, issuerKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
@@ -0,0 +1,392 @@
+package ldif
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rarimo/certificate-transparency-go/asn1"
+	"github.com/rarimo/certificate-transparency-go/x509"
+)
+
+// snapshotFormat is the version of the snapshot encoding
+const snapshotFormat = 1
+
+// ErrSnapshotIntegrity is returned when the snapshot content does not match its hash
+var ErrSnapshotIntegrity = errors.New("snapshot integrity check failed")
+
+// Snapshot is the parsed content of LDIF: certificates, master lists with their
+// metadata, DSCs, CRLs and deviation lists. It is saved as JSON with DER
+// certificates, so loading it needs no base64 LDIF and CMS decoding, only
+// parsing of the certificates and CRLs.
+//
+// Snapshot implements LDIF, so a loaded one can stand in for the source. The
+// results of verification and the diagnostics of loading are not kept.
+type Snapshot struct {
+	CreatedAt time.Time
+	// Hash is hex-encoded SHA-256 of the snapshot content, it is set when the
+	// snapshot is written or read
+	Hash string
+	ldif
+}
+
+// snapshotFile is the encoded snapshot, the hash covers the content bytes as
+// they are written
+type snapshotFile struct {
+	Format  int             `json:"format"`
+	Hash    string          `json:"hash"`
+	Content json.RawMessage `json:"content"`
+}
+
+type snapshotContent struct {
+	PKDVersion int       `json:"pkd_version"`
+	CreatedAt  time.Time `json:"created_at"`
+	// Pool are the unique DER certificates of the master lists and their
+	// signers, the other fields refer to them by index
+	Pool [][]byte `json:"pool"`
+	// Certificates are the pool indexes of the certificates in the order of LDIF.ToX509
+	Certificates    []int                `json:"certificates"`
+	MasterLists     []snapshotMasterList `json:"master_lists,omitempty"`
+	DocumentSigners []snapshotEntry      `json:"document_signers,omitempty"`
+	RevocationLists []snapshotEntry      `json:"revocation_lists,omitempty"`
+	DeviationLists  []snapshotEntry      `json:"deviation_lists,omitempty"`
+}
+
+type snapshotMasterList struct {
+	DN          string    `json:"dn,omitempty"`
+	Country     string    `json:"country,omitempty"`
+	PKDVersion  int       `json:"pkd_version,omitempty"`
+	Version     int       `json:"version"`
+	SigningTime time.Time `json:"signing_time"`
+	// Signer is the pool index of the signer, -1 when the signer is unknown
+	Signer int `json:"signer"`
+	// Certificates are the pool indexes of the parsed certificates
+	Certificates []int `json:"certificates"`
+	// Raw is DER-encoded CMS content of the master list
+	Raw []byte `json:"raw,omitempty"`
+}
+
+// snapshotEntry is a DSC, CRL or deviation list, DER is the ASN.1 structure
+// without CMS for the latter
+type snapshotEntry struct {
+	Country    string `json:"country,omitempty"`
+	DN         string `json:"dn,omitempty"`
+	DER        []byte `json:"der"`
+	PKDVersion int    `json:"pkd_version,omitempty"`
+}
+
+// NewSnapshot takes the content of the LDIF
+func NewSnapshot(l LDIF) *Snapshot {
+	s := &Snapshot{
+		CreatedAt: time.Now().UTC(),
+		ldif: ldif{
+			certificates:    l.ToX509(),
+			masterLists:     l.MasterLists(),
+			provenance:      make(provenanceIndex),
+			documentSigners: l.DocumentSigners(),
+			revocationLists: l.RevocationLists(),
+			deviationLists:  l.DeviationLists(),
+			version:         l.PKDVersion(),
+		},
+	}
+
+	for _, ml := range s.masterLists {
+		s.provenance.add(ml)
+	}
+
+	return s
+}
+
+// ReadSnapshot reads the snapshot written by Snapshot.Write, checking its
+// integrity. The hash can be compared with the expected one additionally.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	var file snapshotFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+
+	if file.Format != snapshotFormat {
+		return nil, fmt.Errorf("unsupported snapshot format %d", file.Format)
+	}
+
+	if hash := snapshotHash(file.Content); hash != file.Hash {
+		return nil, fmt.Errorf("%w: hash %s, expected %s", ErrSnapshotIntegrity, hash, file.Hash)
+	}
+
+	var content snapshotContent
+	if err := json.Unmarshal(file.Content, &content); err != nil {
+		return nil, fmt.Errorf("unmarshal snapshot content: %w", err)
+	}
+
+	return content.snapshot(file.Hash)
+}
+
+// ReadSnapshotFile reads the snapshot from file
+func ReadSnapshotFile(filename string) (*Snapshot, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	s, err := ReadSnapshot(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filename, err)
+	}
+
+	return s, nil
+}
+
+// Write encodes the snapshot and sets its hash
+func (s *Snapshot) Write(w io.Writer) error {
+	content, err := s.content()
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(content)
+	if err != nil {
+		return fmt.Errorf("marshal snapshot content: %w", err)
+	}
+
+	hash := snapshotHash(raw)
+	encoded, err := json.Marshal(snapshotFile{Format: snapshotFormat, Hash: hash, Content: raw})
+	if err != nil {
+		return fmt.Errorf("marshal snapshot: %w", err)
+	}
+
+	if _, err = w.Write(encoded); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+
+	s.Hash = hash
+	return nil
+}
+
+// WriteFile writes the snapshot into file
+func (s *Snapshot) WriteFile(filename string) error {
+	var buf bytes.Buffer
+	if err := s.Write(&buf); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filename, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("writing %s: %w", filename, err)
+	}
+
+	return nil
+}
+
+// CertStore creates a store with the certificates and DSCs of the snapshot
+func (s *Snapshot) CertStore() *CertStore {
+	return NewCertStoreFromLDIF(s)
+}
+
+func (s *Snapshot) content() (snapshotContent, error) {
+	content := snapshotContent{
+		PKDVersion:   s.version,
+		CreatedAt:    s.CreatedAt,
+		Certificates: make([]int, len(s.certificates)),
+	}
+
+	pool := make(map[string]int)
+	add := func(cert *x509.Certificate) int {
+		if cert == nil {
+			return -1
+		}
+
+		fingerprint := Fingerprint(cert)
+		index, ok := pool[fingerprint]
+		if !ok {
+			index = len(content.Pool)
+			pool[fingerprint] = index
+			content.Pool = append(content.Pool, cert.Raw)
+		}
+
+		return index
+	}
+
+	for i, cert := range s.certificates {
+		content.Certificates[i] = add(cert)
+	}
+
+	for _, ml := range s.masterLists {
+		entry := snapshotMasterList{
+			DN:           ml.DN,
+			Country:      ml.Country,
+			PKDVersion:   ml.PKDVersion,
+			Version:      ml.Version,
+			SigningTime:  ml.SigningTime,
+			Signer:       add(ml.Signer),
+			Certificates: make([]int, len(ml.Certificates)),
+			Raw:          ml.Raw,
+		}
+		for i, cert := range ml.Certificates {
+			entry.Certificates[i] = add(cert)
+		}
+
+		content.MasterLists = append(content.MasterLists, entry)
+	}
+
+	for _, signer := range s.documentSigners {
+		content.DocumentSigners = append(content.DocumentSigners, snapshotEntry{
+			Country:    signer.Country,
+			DN:         signer.DN,
+			DER:        signer.Certificate.Raw,
+			PKDVersion: signer.PKDVersion,
+		})
+	}
+
+	for _, crl := range s.revocationLists {
+		content.RevocationLists = append(content.RevocationLists, snapshotEntry{
+			Country:    crl.Country,
+			DN:         crl.DN,
+			DER:        crl.Raw,
+			PKDVersion: crl.PKDVersion,
+		})
+	}
+
+	for _, entry := range s.deviationLists {
+		der, err := asn1.Marshal(entry.List)
+		if err != nil {
+			return snapshotContent{}, fmt.Errorf("marshal deviation list %s: %w", entry.DN, err)
+		}
+
+		content.DeviationLists = append(content.DeviationLists, snapshotEntry{
+			Country:    entry.Country,
+			DN:         entry.DN,
+			DER:        der,
+			PKDVersion: entry.PKDVersion,
+		})
+	}
+
+	return content, nil
+}
+
+func (c snapshotContent) snapshot(hash string) (*Snapshot, error) {
+	s := &Snapshot{
+		CreatedAt: c.CreatedAt,
+		Hash:      hash,
+		ldif: ldif{
+			certificates: make([]*x509.Certificate, len(c.Certificates)),
+			provenance:   make(provenanceIndex),
+			version:      c.PKDVersion,
+		},
+	}
+
+	pool := make([]*x509.Certificate, len(c.Pool))
+	for i, der := range c.Pool {
+		cert, err := parseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate %d: %w", i, err)
+		}
+		pool[i] = cert
+	}
+
+	for i, index := range c.Certificates {
+		cert, err := poolCertificate(pool, index)
+		if err != nil {
+			return nil, fmt.Errorf("certificate %d: %w", i, err)
+		}
+		s.certificates[i] = cert
+	}
+
+	for i, entry := range c.MasterLists {
+		ml := MasterList{
+			Index:        i,
+			DN:           entry.DN,
+			Country:      entry.Country,
+			PKDVersion:   entry.PKDVersion,
+			Version:      entry.Version,
+			SigningTime:  entry.SigningTime,
+			Certificates: make([]*x509.Certificate, len(entry.Certificates)),
+			Raw:          entry.Raw,
+		}
+
+		if entry.Signer != -1 {
+			signer, err := poolCertificate(pool, entry.Signer)
+			if err != nil {
+				return nil, fmt.Errorf("master list %d signer: %w", i, err)
+			}
+			ml.Signer = signer
+		}
+
+		for j, index := range entry.Certificates {
+			cert, err := poolCertificate(pool, index)
+			if err != nil {
+				return nil, fmt.Errorf("master list %d certificate %d: %w", i, j, err)
+			}
+			ml.Certificates[j] = cert
+		}
+		// the list is rebuilt from the parsed certificates
+		ml.List = NewCSCAMasterList(ml.Certificates)
+		ml.List.Version = ml.Version
+
+		s.masterLists = append(s.masterLists, ml)
+		s.provenance.add(ml)
+	}
+
+	for i, entry := range c.DocumentSigners {
+		cert, err := parseCertificate(entry.DER)
+		if err != nil {
+			return nil, fmt.Errorf("parse document signer %d: %w", i, err)
+		}
+
+		s.documentSigners = append(s.documentSigners, DocumentSigner{
+			Country:     entry.Country,
+			DN:          entry.DN,
+			Certificate: cert,
+			PKDVersion:  entry.PKDVersion,
+		})
+	}
+
+	for i, entry := range c.RevocationLists {
+		crl, err := x509.ParseCRL(entry.DER)
+		if err != nil {
+			return nil, fmt.Errorf("parse revocation list %d: %w", i, err)
+		}
+
+		s.revocationLists = append(s.revocationLists, RevocationList{
+			Country:    entry.Country,
+			DN:         entry.DN,
+			CRL:        crl,
+			Raw:        entry.DER,
+			PKDVersion: entry.PKDVersion,
+		})
+	}
+
+	for i, entry := range c.DeviationLists {
+		var list DeviationList
+		if _, err := asn1.Unmarshal(entry.DER, &list); err != nil {
+			return nil, fmt.Errorf("unmarshal deviation list %d: %w", i, err)
+		}
+
+		s.deviationLists = append(s.deviationLists, DeviationListEntry{
+			Country:    entry.Country,
+			DN:         entry.DN,
+			List:       list,
+			PKDVersion: entry.PKDVersion,
+		})
+	}
+
+	return s, nil
+}
+
+func poolCertificate(pool []*x509.Certificate, index int) (*x509.Certificate, error) {
+	if index < 0 || index >= len(pool) {
+		return nil, fmt.Errorf("unknown certificate %d", index)
+	}
+
+	return pool[index], nil
+}
+
+func snapshotHash(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,144 @@
+package ldif
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	deviations := DeviationList{Deviations: []Deviation{{
+		Documents:    DeviationDocuments{DocumentNumbers: []string{"AB1234567"}},
+		Descriptions: []DeviationDescription{{Description: "wrong check digit", DeviationType: OIDDeviationMRZWrongCheckDigit}},
+	}}}
+
+	data := fmt.Sprintf(`dn: cn=OU\=MNIGA-DIC\,O\=GOV\,C\=BW+sn=01,o=dsc,c=BW,dc=data,dc=download,dc=pkd,dc=icao,dc=int
+pkdVersion: 1150
+userCertificate;binary:: %s
+
+dn: cn=O\=GOV\,C\=FI,o=crl,c=FI,dc=data,dc=download,dc=pkd,dc=icao,dc=int
+certificateRevocationList;binary:: %s
+
+dn: cn=CN\=CSCA-BWA\,C\=BW,o=dl,c=BW,dc=data,dc=download,dc=pkd,dc=icao,dc=int
+pkdVersion: 12
+pkdDeviationListContent:: %s
+
+`, base64.StdEncoding.EncodeToString(pemCertDER(t, 0)), base64.StdEncoding.EncodeToString(testCRL(t)),
+		base64.StdEncoding.EncodeToString(deviationListCMS(t, deviations)))
+
+	converter, err := NewLDIF([]byte(data + ldifData + ldifData2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := NewSnapshot(converter)
+
+	var buf bytes.Buffer
+	if err = snapshot.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, snapshot.Hash)
+
+	loaded, err := ReadSnapshot(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, snapshot.Hash, loaded.Hash)
+	assert.True(t, snapshot.CreatedAt.Equal(loaded.CreatedAt))
+	assert.Equal(t, converter.PKDVersion(), loaded.PKDVersion())
+	assert.Equal(t, converter.ToX509(), loaded.ToX509())
+	assert.Equal(t, converter.DocumentSigners(), loaded.DocumentSigners())
+	assert.Equal(t, converter.RevocationLists(), loaded.RevocationLists())
+	assert.Equal(t, converter.DeviationLists(), loaded.DeviationLists())
+
+	lists := loaded.MasterLists()
+	if assert.Len(t, lists, 2) {
+		for i, expected := range converter.MasterLists() {
+			ml := lists[i]
+			assert.Equal(t, i, ml.Index)
+			assert.Equal(t, expected.DN, ml.DN)
+			assert.Equal(t, expected.Country, ml.Country)
+			assert.Equal(t, expected.PKDVersion, ml.PKDVersion)
+			assert.Equal(t, expected.Version, ml.Version)
+			assert.True(t, expected.SigningTime.Equal(ml.SigningTime))
+			assert.Equal(t, expected.Signer, ml.Signer)
+			assert.Equal(t, expected.Certificates, ml.Certificates)
+			assert.Equal(t, expected.Raw, ml.Raw)
+		}
+	}
+
+	for _, cert := range converter.ToX509() {
+		provenance := loaded.Provenance(cert)
+		assert.Equal(t, converter.Provenance(cert), provenance)
+
+		for _, p := range provenance {
+			assert.Equal(t, p.DN, lists[p.MasterListIndex].DN)
+		}
+	}
+
+	expectedKeys, err := converter.RawPubKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := loaded.RawPubKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, expectedKeys, keys)
+
+	assert.Equal(t, NewCertStoreFromLDIF(converter).Len(), loaded.CertStore().Len())
+
+	// the loaded snapshot stands in for the source
+	diff, err := Diff(converter, loaded)
+	if assert.NoError(t, err) {
+		assert.True(t, diff.IsEmpty(), diff)
+	}
+
+	var expectedLDIF, loadedLDIF bytes.Buffer
+	if err = WriteLDIF(&expectedLDIF, converter); err != nil {
+		t.Fatal(err)
+	}
+	if err = WriteLDIF(&loadedLDIF, loaded); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, expectedLDIF.String(), loadedLDIF.String())
+
+	filename := filepath.Join(t.TempDir(), "snapshot.json")
+	if err = loaded.WriteFile(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	fromFile, err := ReadSnapshotFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snapshot.Hash, fromFile.Hash)
+}
+
+func TestSnapshotIntegrity(t *testing.T) {
+	converter, err := NewLDIF([]byte(ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = NewSnapshot(converter).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := bytes.Replace(buf.Bytes(), []byte(`"pkd_version":119`), []byte(`"pkd_version":120`), 1)
+	if !assert.NotEqual(t, buf.Bytes(), tampered) {
+		return
+	}
+
+	_, err = ReadSnapshot(bytes.NewReader(tampered))
+	assert.True(t, errors.Is(err, ErrSnapshotIntegrity), err)
+
+	unsupported := bytes.Replace(buf.Bytes(), []byte(`"format":1`), []byte(`"format":2`), 1)
+	_, err = ReadSnapshot(bytes.NewReader(unsupported))
+	assert.Error(t, err)
+}
@@ -0,0 +1,87 @@
+package ldif
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+)
+
+// SortOrder is the order of the certificates returned by LDIF.ToX509, ToPem and
+// RawPubKeys. Canonical orders depend on the certificates only, so they are
+// stable across ICAO releases.
+type SortOrder int
+
+const (
+	// SortNone keeps the LDIF order: master lists as they appear in LDIF and
+	// certificates in the order of the master list SET
+	SortNone SortOrder = iota
+	// SortBySPKI sorts by SHA-256 fingerprint of the subject public key info
+	SortBySPKI
+	// SortByCountrySubject sorts by subject country, then by subject DN, then
+	// by SPKI fingerprint
+	SortByCountrySubject
+)
+
+// SPKIFingerprint returns hex-encoded SHA-256 hash of the DER subject public key info
+func SPKIFingerprint(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(hash[:])
+}
+
+// SortCertificates sorts the certificates in place. Certificates with equal
+// keys are ordered by their fingerprints, so the result does not depend on the
+// initial order.
+func SortCertificates(certs []*x509.Certificate, order SortOrder) {
+	if order == SortNone {
+		return
+	}
+
+	keys := make(map[*x509.Certificate]sortKey, len(certs))
+	for _, cert := range certs {
+		keys[cert] = newSortKey(cert)
+	}
+
+	sort.SliceStable(certs, func(i, j int) bool {
+		return keys[certs[i]].less(keys[certs[j]], order)
+	})
+}
+
+type sortKey struct {
+	country     string
+	subject     string
+	spki        string
+	fingerprint string
+}
+
+func newSortKey(cert *x509.Certificate) sortKey {
+	key := sortKey{
+		subject:     cert.Subject.String(),
+		spki:        SPKIFingerprint(cert),
+		fingerprint: Fingerprint(cert),
+	}
+	if len(cert.Subject.Country) != 0 {
+		key.country = strings.ToUpper(cert.Subject.Country[0])
+	}
+
+	return key
+}
+
+func (k sortKey) less(other sortKey, order SortOrder) bool {
+	if order == SortByCountrySubject {
+		if k.country != other.country {
+			return k.country < other.country
+		}
+		if k.subject != other.subject {
+			return k.subject < other.subject
+		}
+	}
+
+	if k.spki != other.spki {
+		return k.spki < other.spki
+	}
+
+	return k.fingerprint < other.fingerprint
+}
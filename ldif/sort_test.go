@@ -0,0 +1,54 @@
+package ldif
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortOrder(t *testing.T) {
+	unsorted, err := NewLDIF([]byte(ldifData + ldifData2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reversed, err := NewLDIF([]byte(ldifData2 + ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, unsorted.ToPem(), reversed.ToPem())
+
+	for _, order := range []SortOrder{SortBySPKI, SortByCountrySubject} {
+		first, err := NewLDIF([]byte(ldifData+ldifData2), WithSortOrder(order))
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := NewLDIF([]byte(ldifData2+ldifData), WithSortOrder(order))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, first.ToPem(), second.ToPem())
+		assert.ElementsMatch(t, unsorted.ToPem(), first.ToPem())
+
+		firstKeys, err := first.RawPubKeys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		secondKeys, err := second.RawPubKeys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, firstKeys, secondKeys)
+
+		certs := first.ToX509()
+		for i := 1; i < len(certs); i++ {
+			prev, next := newSortKey(certs[i-1]), newSortKey(certs[i])
+			assert.False(t, next.less(prev, order))
+			if order == SortByCountrySubject {
+				assert.LessOrEqual(t, prev.country, next.country)
+			} else {
+				assert.LessOrEqual(t, prev.spki, next.spki)
+			}
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package ldif
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// Source opens the content of a PKD snapshot: LDIF data, like ICAO PKD
+// collections, stored locally or on the remote server
+type Source interface {
+	// Open starts reading the content, it should be closed by the caller. The
+	// content is read within the source timeout.
+	Open(ctx context.Context) (io.ReadCloser, error)
+}
+
+// ConditionalSource is a Source that can skip the download of the content that
+// was not modified since the previous one
+type ConditionalSource interface {
+	Source
+	// OpenIfModified opens the content unless it still matches the validators
+	// of the previous download, in which case ErrNotModified is returned. Empty
+	// validators make it work like Open.
+	OpenIfModified(ctx context.Context, validators Validators) (io.ReadCloser, Validators, error)
+}
+
+// NamedSource is a Source that knows the name of its content: the file path,
+// the object key or the URL path. FromSource takes the snapshot version from
+// the name of ICAO PKD download, like FromFile does.
+type NamedSource interface {
+	Source
+	Name() string
+}
+
+// ErrNotModified is returned by ConditionalSource when the content was not modified
+var ErrNotModified = errors.New("content not modified")
+
+// Validators identify the version of the remote content, see RFC 9110 section 8.8
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// IsZero reports whether the validators are missing
+func (v Validators) IsZero() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+// setHeaders sets the headers of the conditional request
+func (v Validators) setHeaders(header http.Header) {
+	if v.ETag != "" {
+		header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		header.Set("If-Modified-Since", v.LastModified)
+	}
+}
+
+// SourceOption configures the source
+type SourceOption func(*sourceConfig)
+
+type sourceConfig struct {
+	timeout    time.Duration
+	retries    int
+	backoff    time.Duration
+	httpClient *http.Client
+	endpoint   string
+	// gcsClientOpts are used by GCS source only
+	gcsClientOpts []option.ClientOption
+}
+
+func newSourceConfig(opts []SourceOption) sourceConfig {
+	cfg := sourceConfig{
+		timeout:    downloadingTimeout,
+		backoff:    time.Second,
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return cfg
+}
+
+// WithTimeout limits the time of a single attempt to read the content,
+// including the download of the whole body. Zero disables the limit.
+func WithTimeout(timeout time.Duration) SourceOption {
+	return func(c *sourceConfig) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetries makes the source retry opening the content on transient network
+// failures, 5xx and 429 responses. The delay before each next attempt is doubled.
+func WithRetries(retries int, backoff time.Duration) SourceOption {
+	return func(c *sourceConfig) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// WithHTTPClient sets the client used by HTTP and S3 sources
+func WithHTTPClient(client *http.Client) SourceOption {
+	return func(c *sourceConfig) {
+		c.httpClient = client
+	}
+}
+
+// WithEndpoint overrides the storage endpoint of S3 and GCS sources, e.g. to
+// use S3-compatible storage or a mirror
+func WithEndpoint(endpoint string) SourceOption {
+	return func(c *sourceConfig) {
+		c.endpoint = endpoint
+	}
+}
+
+// StatusError is returned by the remote sources when the server responds with
+// a non-successful status
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("GET %s: unexpected status %s", e.URL, e.Status)
+}
+
+// FromSource creates new LDIF instance from the content opened with the source
+func FromSource(ctx context.Context, src Source, opts ...Option) (LDIF, error) {
+	content, err := src.Open(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("opening source: %w", err)
+	}
+	defer content.Close()
+
+	return FromReaderContext(ctx, content, append([]Option{WithFileName(sourceName(src))}, opts...)...)
+}
+
+// sourceName returns the name of NamedSource, empty for other sources
+func sourceName(src Source) string {
+	if named, ok := src.(NamedSource); ok {
+		return named.Name()
+	}
+
+	return ""
+}
+
+// open calls fn with retries, each attempt has its own timeout that lasts
+// until the returned content is closed
+func (c sourceConfig) open(ctx context.Context, fn func(ctx context.Context) (io.ReadCloser, error)) (io.ReadCloser, error) {
+	backoff := c.backoff
+
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if c.timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, c.timeout)
+		}
+
+		content, err := fn(attemptCtx)
+		if err == nil {
+			return &cancelReadCloser{ReadCloser: content, cancel: cancel}, nil
+		}
+		cancel()
+
+		if attempt >= c.retries || ctx.Err() != nil || !isRetryable(err) {
+			return nil, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// isRetryable tells whether the attempt failed with a transient error: 5xx or
+// 429 status, network timeout, including the attempt timeout, or connection
+// reset. Only opening is retried, the content is read by the caller. The caller
+// stops anyway when the parent context is done.
+func isRetryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return isRetryableStatus(statusErr.StatusCode)
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return isRetryableStatus(apiErr.Code)
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, syscall.ECONNRESET)
+}
+
+func isRetryableStatus(code int) bool {
+	return code >= http.StatusInternalServerError || code == http.StatusTooManyRequests
+}
+
+// cancelReadCloser releases the context of the attempt when the content is closed
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelReadCloser) Close() error {
+	defer r.cancel()
+	return r.ReadCloser.Close()
+}
+
+// HTTPSource reads the content with plain HTTP(S) GET requests
+type HTTPSource struct {
+	url string
+	cfg sourceConfig
+}
+
+// NewHTTPSource creates new source that downloads the content from the URL
+func NewHTTPSource(url string, opts ...SourceOption) *HTTPSource {
+	return &HTTPSource{url: url, cfg: newSourceConfig(opts)}
+}
+
+// Name returns the path of the URL
+func (s *HTTPSource) Name() string {
+	u, err := url.Parse(s.url)
+	if err != nil {
+		return ""
+	}
+
+	return u.Path
+}
+
+func (s *HTTPSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	content, _, err := s.OpenIfModified(ctx, Validators{})
+	return content, err
+}
+
+func (s *HTTPSource) OpenIfModified(ctx context.Context, validators Validators) (io.ReadCloser, Validators, error) {
+	var current Validators
+
+	content, err := s.cfg.open(ctx, func(ctx context.Context) (io.ReadCloser, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+		validators.setHeaders(req.Header)
+
+		var body io.ReadCloser
+		body, current, err = doRequest(s.cfg.httpClient, req)
+		return body, err
+	})
+
+	return content, current, err
+}
+
+// doRequest sends the request and returns the body with the validators of
+// successful response
+func doRequest(client *http.Client, req *http.Request) (io.ReadCloser, Validators, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, Validators{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		resp.Body.Close()
+		return nil, Validators{}, ErrNotModified
+	default:
+		resp.Body.Close()
+		return nil, Validators{}, &StatusError{URL: req.URL.Redacted(), StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return resp.Body, Validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// FileSource reads the content from the local filesystem
+type FileSource struct {
+	path string
+	cfg  sourceConfig
+}
+
+// NewFileSource creates new source that reads the file
+func NewFileSource(path string, opts ...SourceOption) *FileSource {
+	return &FileSource{path: path, cfg: newSourceConfig(opts)}
+}
+
+// Name returns the path of the file
+func (s *FileSource) Name() string {
+	return s.path
+}
+
+func (s *FileSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	return s.cfg.open(ctx, func(ctx context.Context) (io.ReadCloser, error) {
+		file, err := os.Open(s.path)
+		if err != nil {
+			return nil, err
+		}
+
+		return &contextReadCloser{ReadCloser: file, ctx: ctx}, nil
+	})
+}
+
+// contextReadCloser stops reading when the context is done
+type contextReadCloser struct {
+	io.ReadCloser
+	ctx context.Context
+}
+
+func (r *contextReadCloser) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.ReadCloser.Read(p)
+}
@@ -0,0 +1,116 @@
+package ldif
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// GCSSource reads the object from Google Cloud Storage
+type GCSSource struct {
+	bucket     string
+	object     string
+	clientOpts []option.ClientOption
+	cfg        sourceConfig
+}
+
+// WithGCSClientOptions sets the options of the storage client used by GCS
+// source, e.g. option.WithCredentialsFile. Application Default Credentials are
+// used when no credentials are given.
+func WithGCSClientOptions(opts ...option.ClientOption) SourceOption {
+	return func(c *sourceConfig) {
+		c.gcsClientOpts = append(c.gcsClientOpts, opts...)
+	}
+}
+
+// NewGCSSource creates new source that downloads the object from the bucket
+func NewGCSSource(bucket, object string, opts ...SourceOption) *GCSSource {
+	cfg := newSourceConfig(opts)
+
+	clientOpts := cfg.gcsClientOpts
+	if cfg.endpoint != "" {
+		clientOpts = append(clientOpts, option.WithEndpoint(cfg.endpoint))
+	}
+
+	return &GCSSource{
+		bucket:     bucket,
+		object:     object,
+		clientOpts: clientOpts,
+		cfg:        cfg,
+	}
+}
+
+// Name returns the name of the object
+func (s *GCSSource) Name() string {
+	return s.object
+}
+
+func (s *GCSSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	content, _, err := s.OpenIfModified(ctx, Validators{})
+	return content, err
+}
+
+// OpenIfModified uses the object generation as ETag, so only the validators
+// returned by GCS source can be used
+func (s *GCSSource) OpenIfModified(ctx context.Context, validators Validators) (io.ReadCloser, Validators, error) {
+	var conds storage.Conditions
+	if validators.ETag != "" {
+		generation, err := strconv.ParseInt(validators.ETag, 10, 64)
+		if err != nil {
+			return nil, Validators{}, fmt.Errorf("parse object generation %q: %w", validators.ETag, err)
+		}
+		conds.GenerationNotMatch = generation
+	}
+
+	var current Validators
+
+	content, err := s.cfg.open(ctx, func(ctx context.Context) (io.ReadCloser, error) {
+		client, err := storage.NewClient(ctx, s.clientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("creating new storage client: %w", err)
+		}
+
+		object := client.Bucket(s.bucket).Object(s.object)
+		if conds.GenerationNotMatch != 0 {
+			object = object.If(conds)
+		}
+
+		reader, err := object.NewReader(ctx)
+		if err != nil {
+			client.Close()
+
+			var apiErr *googleapi.Error
+			if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotModified {
+				return nil, ErrNotModified
+			}
+			return nil, fmt.Errorf("reading content %s from %s: %w", s.object, s.bucket, err)
+		}
+
+		current = Validators{ETag: strconv.FormatInt(reader.Attrs.Generation, 10)}
+		if !reader.Attrs.LastModified.IsZero() {
+			current.LastModified = reader.Attrs.LastModified.UTC().Format(http.TimeFormat)
+		}
+
+		return &gcsReadCloser{Reader: reader, client: client}, nil
+	})
+
+	return content, current, err
+}
+
+// gcsReadCloser closes the storage client along with the object reader
+type gcsReadCloser struct {
+	*storage.Reader
+	client *storage.Client
+}
+
+func (r *gcsReadCloser) Close() error {
+	defer r.client.Close()
+	return r.Reader.Close()
+}
@@ -0,0 +1,173 @@
+package ldif
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3SigningAlgorithm = "AWS4-HMAC-SHA256"
+	s3AmzDateFormat    = "20060102T150405Z"
+	s3DateFormat       = "20060102"
+	// s3EmptyPayloadHash is SHA-256 of the empty body of GET request
+	s3EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Credentials are AWS access keys used to sign S3 requests
+type S3Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials only
+	SessionToken string
+}
+
+// S3Source reads the object from Amazon S3 or S3-compatible storage with
+// requests signed with AWS Signature Version 4
+type S3Source struct {
+	region      string
+	bucket      string
+	key         string
+	credentials S3Credentials
+	cfg         sourceConfig
+}
+
+// NewS3Source creates new source that downloads the object from the bucket. The
+// path-style URL of the regional AWS endpoint is used unless WithEndpoint is set.
+func NewS3Source(region, bucket, key string, credentials S3Credentials, opts ...SourceOption) *S3Source {
+	cfg := newSourceConfig(opts)
+	if cfg.endpoint == "" {
+		cfg.endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+
+	return &S3Source{
+		region:      region,
+		bucket:      bucket,
+		key:         key,
+		credentials: credentials,
+		cfg:         cfg,
+	}
+}
+
+// Name returns the key of the object
+func (s *S3Source) Name() string {
+	return s.key
+}
+
+func (s *S3Source) Open(ctx context.Context) (io.ReadCloser, error) {
+	content, _, err := s.OpenIfModified(ctx, Validators{})
+	return content, err
+}
+
+func (s *S3Source) OpenIfModified(ctx context.Context, validators Validators) (io.ReadCloser, Validators, error) {
+	var (
+		url     = strings.TrimSuffix(s.cfg.endpoint, "/") + s3EscapePath(s.bucket+"/"+s.key)
+		current Validators
+	)
+
+	content, err := s.cfg.open(ctx, func(ctx context.Context) (io.ReadCloser, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+		validators.setHeaders(req.Header)
+		signS3Request(req, s.credentials, s.region, time.Now())
+
+		var body io.ReadCloser
+		body, current, err = doRequest(s.cfg.httpClient, req)
+		return body, err
+	})
+
+	return content, current, err
+}
+
+// signS3Request adds AWS Signature Version 4 authorization to the request with
+// empty body. All the request headers are signed, along with the host.
+func signS3Request(req *http.Request, credentials S3Credentials, region string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(s3AmzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", s3EmptyPayloadHash)
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(strings.TrimPrefix(req.URL.Path, "/")),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		s3EmptyPayloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{now.Format(s3DateFormat), region, "s3", "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		s3SigningAlgorithm,
+		now.Format(s3AmzDateFormat),
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + credentials.SecretAccessKey)
+	for _, part := range []string{now.Format(s3DateFormat), region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		s3SigningAlgorithm, credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath URI-encodes the object path as required by SigV4, leaving the
+// slashes and unreserved characters as is
+func s3EscapePath(path string) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	var b strings.Builder
+	b.WriteByte('/')
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0xf])
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package ldif
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+)
+
+// ErrStopWalk can be returned from a walk callback to stop reading without an error
+var ErrStopWalk = errors.New("stop walk")
+
+// WalkMasterLists reads LDIF records from the reader one by one and calls fn for
+// every decoded master list. Only the current record is kept in memory, so the
+// input of any size can be processed.
+func WalkMasterLists(r io.Reader, fn func(CSCAMasterList) error) error {
+	return walkRecords(r, func(record *Record) error {
+		for _, content := range record.Values(masterListContentAttr) {
+			list, err := ParseMasterList(content)
+			if err != nil {
+				return fmt.Errorf("parse master list %s: %w", record.DN, err)
+			}
+
+			if err = fn(list); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// WalkCertificates reads LDIF from the reader and calls fn for every certificate
+// of every master list. Certificates come in the default (unsorted) order of
+// LDIF.ToX509, WithSortOrder is not applied. Only raw LDIF is read, it is never
+// buffered as a whole.
+func WalkCertificates(r io.Reader, fn func(*x509.Certificate) error) error {
+	return WalkMasterLists(r, func(list CSCAMasterList) error {
+		certs, err := list.ToX509()
+		if err != nil {
+			return fmt.Errorf("extract x509 certificates from master list: %w", err)
+		}
+
+		for _, cert := range certs {
+			if err = fn(cert); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// walkRecords calls fn for every LDIF record read from the reader, stopping
+// silently when fn returns ErrStopWalk
+func walkRecords(r io.Reader, fn func(*Record) error) error {
+	parser := NewParser(r)
+
+	for {
+		record, err := parser.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("parse LDIF record: %w", err)
+		}
+
+		if err = fn(record); err != nil {
+			if errors.Is(err, ErrStopWalk) {
+				return nil
+			}
+			return err
+		}
+	}
+}
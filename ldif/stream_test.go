@@ -0,0 +1,46 @@
+package ldif
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalkCertificates(t *testing.T) {
+	converter, err := NewLDIF([]byte(ldifData + ldifData2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var walked []*x509.Certificate
+	err = WalkCertificates(strings.NewReader(ldifData+ldifData2), func(cert *x509.Certificate) error {
+		walked = append(walked, cert)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := converter.ToX509()
+	if len(walked) != len(expected) {
+		t.Fatalf("certificates count: want %d, got %d", len(expected), len(walked))
+	}
+	for i := range expected {
+		assert.Equal(t, expected[i].Raw, walked[i].Raw)
+	}
+}
+
+func TestWalkMasterListsStop(t *testing.T) {
+	var count int
+	err := WalkMasterLists(strings.NewReader(ldifData+ldifData2), func(CSCAMasterList) error {
+		count++
+		return ErrStopWalk
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, count)
+}
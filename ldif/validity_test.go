@@ -0,0 +1,54 @@
+package ldif_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rarimo/certificate-transparency-go/x509"
+	"github.com/rarimo/ldif-sdk/ldif"
+	"github.com/rarimo/ldif-sdk/ldif/ldiftest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidity(t *testing.T) {
+	const year = 365 * 24 * time.Hour
+
+	// the first CSCA expired a month ago, the second one expires in 11 months
+	now := time.Now()
+	issued := now.Add(-8*year - 30*24*time.Hour)
+
+	pki, err := ldiftest.New(ldiftest.WithCountries("XA"), ldiftest.WithKeyType(ldiftest.ECDSAP256),
+		ldiftest.WithGenerations(2), ldiftest.WithTime(issued))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := pki.LDIF()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := ldif.NewLDIF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		xa   = pki.Country("XA")
+		old  = xa.CSCAs[0].Certificate
+		next = xa.CSCA().Certificate
+		link = xa.Links[0]
+	)
+
+	assert.Len(t, l.ToX509(), 3)
+	assertCertificates(t, []*x509.Certificate{next, link}, l.ValidAt(now))
+	// a document signed before the rollover
+	assertCertificates(t, []*x509.Certificate{old}, l.ValidAt(old.NotBefore.Add(time.Hour)))
+	assertCertificates(t, []*x509.Certificate{old, next, link}, l.ValidAt(issued))
+	// empty like ToX509 of empty LDIF
+	assert.Equal(t, []*x509.Certificate{}, l.ValidAt(now.Add(2*year)))
+
+	assertCertificates(t, []*x509.Certificate{next, link}, l.ExpiringWithin(now, year))
+	assert.Equal(t, []*x509.Certificate{}, l.ExpiringWithin(now, 10*24*time.Hour))
+	assertCertificates(t, []*x509.Certificate{old}, l.ExpiringWithin(issued, 8*year+180*24*time.Hour))
+}
@@ -0,0 +1,115 @@
+package ldif
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/github/smimesign/ietf-cms/protocol"
+	"github.com/rarimo/certificate-transparency-go/x509"
+)
+
+// ErrUntrustedSigner is returned when master list signer certificate is not
+// issued by any trusted CSCA of the issuing country
+var ErrUntrustedSigner = errors.New("signer is not issued by a CSCA of the country")
+
+// SignatureStatus is the result of master list signature verification
+type SignatureStatus int
+
+const (
+	SignatureNotVerified SignatureStatus = iota
+	SignatureValid
+	SignatureInvalid
+)
+
+func (s SignatureStatus) String() string {
+	switch s {
+	case SignatureValid:
+		return "valid"
+	case SignatureInvalid:
+		return "invalid"
+	default:
+		return "not verified"
+	}
+}
+
+// Verification is the result of verification of a single master list
+type Verification struct {
+	// Index is the index of the master list in LDIF.MasterLists
+	Index   int
+	DN      string
+	Country string
+	Status  SignatureStatus
+	// Signer is the Master List Signer certificate, when it was found
+	Signer *x509.Certificate
+	// Issuer is the CSCA that issued the signer certificate
+	Issuer *x509.Certificate
+	// Err describes why verification failed
+	Err error
+}
+
+// verifyMasterListSignature checks the CMS signature of a master list, the
+// signer chain is checked later, when all the CSCAs are known
+func verifyMasterListSignature(ml MasterList, signedData *protocol.SignedData, content []byte) Verification {
+	verification := Verification{
+		Index:   ml.Index,
+		DN:      ml.DN,
+		Country: ml.Country,
+		Status:  SignatureInvalid,
+	}
+
+	signer, err := verifySignedData(signedData, content)
+	if err != nil {
+		verification.Err = err
+		return verification
+	}
+
+	verification.Signer = signer
+	return verification
+}
+
+// verifySignerChain looks for a CSCA of the issuing country that has issued
+// the signer certificate
+func verifySignerChain(verification *Verification, cscas ...[]*x509.Certificate) {
+	if verification.Signer == nil {
+		return
+	}
+
+	for _, set := range cscas {
+		for _, csca := range set {
+			if !isCountryCertificate(csca, verification.Country) {
+				continue
+			}
+
+			if issuedBy(verification.Signer, csca) {
+				verification.Issuer = csca
+				verification.Status = SignatureValid
+				return
+			}
+		}
+	}
+
+	verification.Err = ErrUntrustedSigner
+}
+
+func isCountryCertificate(cert *x509.Certificate, country string) bool {
+	for _, c := range cert.Subject.Country {
+		if strings.EqualFold(c, country) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verificationsError joins errors of all the failed verifications
+func verificationsError(verifications []Verification) error {
+	var errs []error
+	for _, v := range verifications {
+		if v.Status != SignatureValid {
+			errs = append(errs, fmt.Errorf("master list %d %s: %w", v.Index, v.DN, v.Err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,126 @@
+package ldif
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifications(t *testing.T) {
+	data := []byte(ldifData + ldifData2)
+	converter, err := NewLDIF(data, WithSignatureVerification(), testTrustAnchors(t, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifications := converter.Verifications()
+	if !assert.Len(t, verifications, 2) {
+		return
+	}
+
+	for i, country := range []string{"BW", "FI"} {
+		v := verifications[i]
+		assert.Equal(t, i, v.Index)
+		assert.Equal(t, country, v.Country)
+		assert.Equal(t, SignatureValid, v.Status, "%s: %v", country, v.Err)
+		assert.NotNil(t, v.Signer)
+		assert.NotNil(t, v.Issuer)
+	}
+}
+
+func TestVerificationsUntrusted(t *testing.T) {
+	// the lists of different countries do not vouch for each other
+	data := []byte(ldifData + ldifData2)
+	converter, err := NewLDIF(data, WithSignatureVerification())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range converter.Verifications() {
+		assert.Equal(t, SignatureInvalid, v.Status)
+		assert.ErrorIs(t, v.Err, ErrUntrustedSigner)
+		assert.NotNil(t, v.Signer)
+	}
+
+	_, err = NewLDIF(data, WithStrictVerification())
+	assert.ErrorIs(t, err, ErrUntrustedSigner)
+
+	// a copy of the list vouches for the list
+	converter, err = NewLDIF([]byte(ldifData+ldifData), WithStrictVerification())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range converter.Verifications() {
+		assert.Equal(t, SignatureValid, v.Status)
+		assert.NotNil(t, v.Issuer)
+	}
+
+	// the anchors of another country are not trusted
+	_, err = NewLDIF([]byte(ldifData), WithStrictVerification(), testTrustAnchors(t, []byte(ldifData2)))
+	assert.ErrorIs(t, err, ErrUntrustedSigner)
+}
+
+func TestVerificationsDisabled(t *testing.T) {
+	converter, err := NewLDIF([]byte(ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, converter.Verifications())
+}
+
+func TestVerificationsTampered(t *testing.T) {
+	records, err := ParseRecords([]byte(ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := records[2].Value(masterListContentAttr)
+	cert := pemCertDER(t, 1)
+
+	// flip a bit of the certificate signature inside of the master list content
+	offset := bytes.Index(content, cert) + len(cert) - 1
+	tampered := append([]byte{}, content...)
+	tampered[offset] ^= 1
+
+	data := fmt.Sprintf("dn: %s\npkdMasterListContent:: %s\n", records[2].DN, base64.StdEncoding.EncodeToString(tampered))
+
+	converter, err := NewLDIF([]byte(data), WithSignatureVerification())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := converter.Verifications()[0]
+	assert.Equal(t, SignatureInvalid, v.Status)
+	assert.True(t, errors.Is(v.Err, ErrInvalidSignature), v.Err)
+
+	_, err = NewLDIF([]byte(data), WithStrictVerification())
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+// testTrustAnchors trusts the CSCAs of the LDIF data, as if they were
+// obtained out of band
+func testTrustAnchors(t *testing.T, data []byte) Option {
+	l, err := NewLDIF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return WithTrustAnchors(l.ToX509())
+}
+
+// pemCertDER returns DER bytes of PEMCerts entry
+func pemCertDER(t *testing.T, i int) []byte {
+	block, _ := pem.Decode([]byte(PEMCerts[i]))
+	if block == nil {
+		t.Fatal("failed to decode pem block")
+	}
+
+	return block.Bytes
+}
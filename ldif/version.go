@@ -0,0 +1,101 @@
+package ldif
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const pkdVersionAttr = "pkdVersion"
+
+// ErrRollback is returned when the snapshot is replaced with an older one
+var ErrRollback = errors.New("PKD version rollback")
+
+// ErrNoSnapshot is returned when the snapshot to check or set is nil
+var ErrNoSnapshot = errors.New("no snapshot")
+
+// pkdFileNameRegexp matches ICAO PKD download names, like icaopkd-002-complete-000305.ldif
+var pkdFileNameRegexp = regexp.MustCompile(`(?i)^icaopkd-\d+-[a-z]+-(\d+)`)
+
+// VersionFromFileName returns the sequence number of ICAO PKD download, e.g.
+// 305 for icaopkd-002-complete-000305.ldif. It returns false when the name does
+// not follow ICAO naming.
+func VersionFromFileName(name string) (int, bool) {
+	match := pkdFileNameRegexp.FindStringSubmatch(filepath.Base(name))
+	if match == nil {
+		return 0, false
+	}
+
+	version, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+
+	return version, true
+}
+
+// recordVersion returns the pkdVersion of the record, 0 when it is absent
+func recordVersion(record *Record) (int, error) {
+	value := record.Value(pkdVersionAttr)
+	if value == nil {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(strings.TrimSpace(string(value)))
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid %s %q", pkdVersionAttr, value)
+	}
+
+	return version, nil
+}
+
+// CheckVersion returns ErrRollback when the next snapshot has lower PKD version
+// than the current one. Snapshots of unknown version (0) are considered older
+// than any known one. A nil next snapshot fails with ErrNoSnapshot.
+func CheckVersion(current, next LDIF) error {
+	if next == nil {
+		return ErrNoSnapshot
+	}
+
+	if current == nil {
+		return nil
+	}
+
+	if next.PKDVersion() < current.PKDVersion() {
+		return fmt.Errorf("%w: version %d is older than %d", ErrRollback, next.PKDVersion(), current.PKDVersion())
+	}
+
+	return nil
+}
+
+// Latest holds the latest loaded snapshot and refuses to replace it with an
+// older one. It is safe for concurrent use.
+type Latest struct {
+	mu   sync.RWMutex
+	ldif LDIF
+}
+
+// Get returns the current snapshot, nil when none was set
+func (l *Latest) Get() LDIF {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.ldif
+}
+
+// Replace sets the next snapshot, unless it is older than the current one, see CheckVersion
+func (l *Latest) Replace(next LDIF) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := CheckVersion(l.ldif, next); err != nil {
+		return err
+	}
+
+	l.ldif = next
+	return nil
+}
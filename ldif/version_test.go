@@ -0,0 +1,125 @@
+package ldif
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionFromFileName(t *testing.T) {
+	testCases := []struct {
+		name    string
+		version int
+		ok      bool
+	}{
+		{name: "icaopkd-002-complete-000305.ldif", version: 305, ok: true},
+		{name: "/data/icaopkd-001-delta-009669.ldif", version: 9669, ok: true},
+		{name: "ICAOPKD-003-COMPLETE-000012.zip", version: 12, ok: true},
+		{name: "icao-list.ldif"},
+		{name: ""},
+	}
+
+	for _, test := range testCases {
+		version, ok := VersionFromFileName(test.name)
+		assert.Equal(t, test.ok, ok, test.name)
+		assert.Equal(t, test.version, version, test.name)
+	}
+}
+
+func TestPKDVersion(t *testing.T) {
+	converter, err := NewLDIF([]byte(ldifData + ldifData2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 153, converter.PKDVersion())
+	lists := converter.MasterLists()
+	if assert.Len(t, lists, 2) {
+		assert.Equal(t, 119, lists[0].PKDVersion)
+		assert.Equal(t, 153, lists[1].PKDVersion)
+	}
+
+	filename := filepath.Join(t.TempDir(), "icaopkd-002-complete-000305.ldif")
+	if err = os.WriteFile(filename, []byte(ldifData), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fromFile, err := FromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 305, fromFile.PKDVersion())
+
+	zipped, err := NewLDIF(zipData(t, map[string]string{"icaopkd-002-complete-000400.ldif": ldifData}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 400, zipped.PKDVersion())
+
+	invalid := strings.Replace(ldifData, "pkdVersion: 119", "pkdVersion: latest", 1)
+	_, err = NewLDIF([]byte(invalid))
+	assert.Error(t, err)
+
+	lenient, err := NewLDIF([]byte(invalid), WithLenientParsing())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, lenient.Diagnostics(), 1)
+	assert.Len(t, lenient.ToX509(), 2)
+}
+
+func TestSourceVersion(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "icaopkd-002-complete-000305.ldif")
+	if err := os.WriteFile(filename, []byte(ldifData), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fromFile, err := FromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromSource, err := FromSource(context.Background(), NewFileSource(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 305, fromSource.PKDVersion())
+	assert.Equal(t, fromFile.PKDVersion(), fromSource.PKDVersion())
+
+	named, err := FromReader(strings.NewReader(ldifData), WithFileName("icaopkd-002-complete-000310.ldif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 310, named.PKDVersion())
+
+	assert.Equal(t, "/pkd/icaopkd-002-complete-000305.ldif", NewHTTPSource("https://example.com/pkd/icaopkd-002-complete-000305.ldif?x=1").Name())
+}
+
+func TestLatest(t *testing.T) {
+	bw, err := NewLDIF([]byte(ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := NewLDIF([]byte(ldifData2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var latest Latest
+	assert.Nil(t, latest.Get())
+
+	assert.NoError(t, latest.Replace(bw))
+	assert.NoError(t, latest.Replace(fi))
+	assert.NoError(t, latest.Replace(fi))
+	assert.ErrorIs(t, latest.Replace(bw), ErrRollback)
+	assert.Equal(t, fi, latest.Get())
+
+	assert.ErrorIs(t, CheckVersion(fi, nil), ErrNoSnapshot)
+	assert.ErrorIs(t, CheckVersion(nil, nil), ErrNoSnapshot)
+	assert.ErrorIs(t, latest.Replace(nil), ErrNoSnapshot)
+	assert.Equal(t, fi, latest.Get())
+}
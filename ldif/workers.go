@@ -0,0 +1,85 @@
+package ldif
+
+import (
+	"sync/atomic"
+)
+
+// pendingRecord is the record waiting for its master list entries to be
+// decoded by the workers
+type pendingRecord struct {
+	record      *Record
+	masterLists []decodedMasterList
+	remaining   int32
+	// done is closed when all the master lists of the record are decoded
+	done chan struct{}
+}
+
+// queue adds the record. With several workers its master lists are decoded in
+// the background and the record is added later by flush, in the reading order.
+func (ld *loader) queue(record *Record) error {
+	if ld.workers == nil {
+		if err := ld.addRecord(record, ld.decodeMasterLists(record)); err != nil {
+			return err
+		}
+
+		ld.report()
+		return nil
+	}
+
+	values := record.Values(masterListContentAttr)
+	pending := &pendingRecord{
+		record:      record,
+		masterLists: make([]decodedMasterList, len(values)),
+		remaining:   int32(len(values)),
+		done:        make(chan struct{}),
+	}
+	if len(values) == 0 {
+		close(pending.done)
+	}
+
+	for i, content := range values {
+		index := ld.masterListEntries
+		ld.masterListEntries++
+
+		// blocks while all the workers are busy
+		ld.workers <- struct{}{}
+		go func(i, index int, content []byte) {
+			pending.masterLists[i] = decodeMasterList(index, record.DN, content, ld.cfg.verifySignatures)
+			<-ld.workers
+
+			if atomic.AddInt32(&pending.remaining, -1) == 0 {
+				close(pending.done)
+			}
+		}(i, index, content)
+	}
+
+	ld.pending = append(ld.pending, pending)
+	return ld.flush(2 * cap(ld.workers))
+}
+
+// flush adds the pending records that are decoded already, waiting for the
+// oldest ones while more than limit records are pending
+func (ld *loader) flush(limit int) error {
+	for len(ld.pending) != 0 {
+		pending := ld.pending[0]
+		if len(ld.pending) > limit {
+			<-pending.done
+		} else {
+			select {
+			case <-pending.done:
+			default:
+				return nil
+			}
+		}
+
+		ld.pending[0] = nil
+		ld.pending = ld.pending[1:]
+
+		if err := ld.addRecord(pending.record, pending.masterLists); err != nil {
+			return err
+		}
+		ld.report()
+	}
+
+	return nil
+}
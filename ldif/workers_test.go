@@ -0,0 +1,156 @@
+package ldif
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// workersData has valid and broken master lists, a malformed record and a
+// broken DSC between the master lists
+func workersData(t testing.TB) []byte {
+	records, err := ParseRecords([]byte(ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broken := fmt.Sprintf(`dn: %s
+pkdMasterListContent:: AQID
+
+malformed record
+
+dn: cn=CN\=DS,o=dsc,c=BW,dc=data,dc=download,dc=pkd,dc=icao,dc=int
+userCertificate;binary:: AQID
+
+`, records[2].DN)
+
+	return []byte(strings.Repeat(ldifData+broken+ldifData2, 4))
+}
+
+func TestWorkers(t *testing.T) {
+	data := workersData(t)
+
+	load := func(opts ...Option) (LDIF, Progress) {
+		var last Progress
+		opts = append(opts, WithLenientParsing(), WithSignatureVerification(), WithProgress(func(p Progress) {
+			last = p
+		}))
+
+		l, err := NewLDIF(data, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return l, last
+	}
+
+	sequential, sequentialProgress := load()
+	assert.Len(t, sequential.MasterLists(), 8)
+	assert.Len(t, sequential.Diagnostics(), 12)
+	for i, ml := range sequential.MasterLists() {
+		assert.Equal(t, i, ml.Index)
+	}
+
+	for _, workers := range []int{2, 3, 16} {
+		parallel, parallelProgress := load(WithWorkers(workers))
+		assert.Equal(t, sequential, parallel, workers)
+		assert.Equal(t, sequentialProgress, parallelProgress, workers)
+	}
+
+	_, err := NewLDIF(data)
+	var sequentialErr Diagnostic
+	if !assert.True(t, errors.As(err, &sequentialErr)) {
+		return
+	}
+
+	_, err = NewLDIF(data, WithWorkers(4))
+	var parallelErr Diagnostic
+	if assert.True(t, errors.As(err, &parallelErr)) {
+		assert.Equal(t, sequentialErr, parallelErr)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = NewLDIFContext(ctx, data, WithWorkers(4))
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestExtractMasterListsConcurrent(t *testing.T) {
+	converter, err := NewLDIF([]byte(strings.Repeat(ldifData+ldifData2, 4)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw [][]byte
+	for _, ml := range converter.MasterLists() {
+		raw = append(raw, ml.Raw)
+	}
+
+	var sequentialReports, parallelReports []Progress
+	sequential, err := ExtractMasterListsContext(context.Background(), raw, func(p Progress) {
+		sequentialReports = append(sequentialReports, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parallel, err := ExtractMasterListsConcurrent(context.Background(), raw, 3, func(p Progress) {
+		parallelReports = append(parallelReports, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, sequential, parallel)
+	assert.Equal(t, sequentialReports, parallelReports)
+
+	broken := append([][]byte{}, raw...)
+	broken[5] = []byte{1, 2, 3}
+
+	_, sequentialErr := ExtractMasterLists(broken)
+	_, parallelErr := ExtractMasterListsConcurrent(context.Background(), broken, 3, nil)
+	if assert.Error(t, parallelErr) {
+		assert.Equal(t, sequentialErr.Error(), parallelErr.Error())
+	}
+}
+
+// icaoListPath is the ICAO PKD master list collection the mt tests use, it is
+// not committed and has to be downloaded from ICAO PKD
+const icaoListPath = "../mt/icao-list.ldif"
+
+func BenchmarkWorkers(b *testing.B) {
+	data, err := os.ReadFile(icaoListPath)
+	if errors.Is(err, os.ErrNotExist) {
+		b.Skipf("%s is missing, download ICAO master lists to run the benchmark", icaoListPath)
+	}
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	benchmarkWorkers(b, data)
+}
+
+// BenchmarkWorkersFixture is the fallback on the test data, its few master
+// lists say little about the speedup on the real collection
+func BenchmarkWorkersFixture(b *testing.B) {
+	benchmarkWorkers(b, []byte(strings.Repeat(ldifData+ldifData2, 32)))
+}
+
+func benchmarkWorkers(b *testing.B, data []byte) {
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := NewLDIF(data, WithSignatureVerification(), WithWorkers(workers)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
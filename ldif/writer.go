@@ -0,0 +1,208 @@
+package ldif
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxLineLength is the length of the lines after which they are folded
+const maxLineLength = 76
+
+// Writer writes LDIF records, see RFC 2849. Values that are not safe strings
+// are base64-encoded and long lines are folded.
+type Writer struct {
+	w       *bufio.Writer
+	written bool
+}
+
+// NewWriter creates new writer, Flush must be called after the records are written
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// WriteVersion writes `version: 1` line, it must be called before any record
+func (w *Writer) WriteVersion() error {
+	if w.written {
+		return fmt.Errorf("version must be written before the records")
+	}
+
+	w.written = true
+	return w.writeLine("version: 1")
+}
+
+// Write writes the record. Line of the record is ignored.
+func (w *Writer) Write(record *Record) error {
+	if w.written {
+		if err := w.writeLine(""); err != nil {
+			return err
+		}
+	}
+	w.written = true
+
+	if err := w.writeAttribute("dn", []byte(record.DN)); err != nil {
+		return err
+	}
+
+	for _, control := range record.Controls {
+		if err := w.writeControl(control); err != nil {
+			return err
+		}
+	}
+
+	if record.ChangeType != ChangeTypeNone {
+		if err := w.writeAttribute("changetype", []byte(record.ChangeType)); err != nil {
+			return err
+		}
+	}
+
+	switch record.ChangeType {
+	case ChangeTypeNone, ChangeTypeAdd:
+		return w.writeAttributes(record.Attributes)
+	case ChangeTypeDelete:
+		return nil
+	case ChangeTypeModRDN, ChangeTypeModDN:
+		return w.writeModRDN(record)
+	case ChangeTypeModify:
+		return w.writeModify(record)
+	default:
+		return fmt.Errorf("unknown changetype %q", record.ChangeType)
+	}
+}
+
+// Flush writes the buffered data to the underlying writer
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+func (w *Writer) writeAttributes(attrs []Attribute) error {
+	for _, attr := range attrs {
+		var err error
+		if attr.URL != "" {
+			err = w.writeLine(attr.Name + ":< " + attr.URL)
+		} else {
+			err = w.writeAttribute(attr.Name, attr.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) writeModRDN(record *Record) error {
+	deleteOldRDN := "0"
+	if record.DeleteOldRDN {
+		deleteOldRDN = "1"
+	}
+
+	attrs := []Attribute{
+		{Name: "newrdn", Value: []byte(record.NewRDN)},
+		{Name: "deleteoldrdn", Value: []byte(deleteOldRDN)},
+	}
+	if record.NewSuperior != "" {
+		attrs = append(attrs, Attribute{Name: "newsuperior", Value: []byte(record.NewSuperior)})
+	}
+
+	return w.writeAttributes(attrs)
+}
+
+func (w *Writer) writeModify(record *Record) error {
+	for _, mod := range record.Modifications {
+		if err := w.writeAttribute(string(mod.Op), []byte(mod.Attribute)); err != nil {
+			return err
+		}
+		if err := w.writeAttributes(mod.Values); err != nil {
+			return err
+		}
+		if err := w.writeLine("-"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) writeControl(control Control) error {
+	line := "control: " + control.OID
+	if control.Criticality {
+		line += " true"
+	}
+	if control.Value != nil {
+		line += valueSpec(control.Value)
+	}
+
+	return w.writeLine(line)
+}
+
+func (w *Writer) writeAttribute(name string, value []byte) error {
+	return w.writeLine(name + valueSpec(value))
+}
+
+// writeLine writes the line folding it by maxLineLength
+func (w *Writer) writeLine(line string) error {
+	var b strings.Builder
+
+	for first := true; ; first = false {
+		limit := maxLineLength
+		if !first {
+			// continuation lines start with a space
+			b.WriteByte(' ')
+			limit--
+		}
+
+		if len(line) <= limit {
+			b.WriteString(line)
+			b.WriteByte('\n')
+			break
+		}
+
+		b.WriteString(line[:limit])
+		b.WriteByte('\n')
+		line = line[limit:]
+	}
+
+	if _, err := w.w.WriteString(b.String()); err != nil {
+		return fmt.Errorf("write LDIF: %w", err)
+	}
+
+	return nil
+}
+
+// valueSpec returns the part of the line starting with the colon, the value is
+// base64-encoded unless it is a safe string
+func valueSpec(value []byte) string {
+	if isSafeString(value) {
+		return ": " + string(value)
+	}
+
+	return ":: " + base64.StdEncoding.EncodeToString(value)
+}
+
+// isSafeString reports whether the value can be written as is, see SAFE-STRING
+// in RFC 2849. Values with trailing spaces are not safe either, as they are
+// often stripped by the editors.
+func isSafeString(value []byte) bool {
+	if len(value) == 0 {
+		return true
+	}
+
+	switch value[0] {
+	case ' ', ':', '<':
+		return false
+	}
+	if value[len(value)-1] == ' ' {
+		return false
+	}
+
+	for _, c := range value {
+		if c == 0 || c == '\n' || c == '\r' || c > 127 {
+			return false
+		}
+	}
+
+	return true
+}
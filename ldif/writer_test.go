@@ -0,0 +1,69 @@
+package ldif
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	records, err := ParseRecords([]byte(changesLDIF + "\n" + ldifData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records = append(records, Record{
+		DN: "cn=Unsafe,dc=example,dc=com",
+		Controls: []Control{
+			{OID: "1.2.3.4", Value: []byte("plain")},
+			{OID: "1.2.3.5", Criticality: true, Value: []byte{0, 1, 2}},
+		},
+		ChangeType: ChangeTypeModify,
+		Modifications: []Modification{{
+			Op:        ModOpReplace,
+			Attribute: "description",
+			Values: []Attribute{
+				{Name: "description", Value: []byte(" leading space")},
+				{Name: "description", Value: []byte("trailing space ")},
+				{Name: "description", Value: []byte(":colon")},
+				{Name: "description", Value: []byte("юникод")},
+				{Name: "description", Value: []byte(strings.Repeat("long ", 40))},
+			},
+		}},
+	})
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err = w.WriteVersion(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range records {
+		if err = w.Write(&records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(buf.String(), "\n") {
+		assert.LessOrEqual(t, len(line), maxLineLength)
+	}
+
+	written, err := ParseRecords(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Len(t, written, len(records)) {
+		return
+	}
+
+	for i := range records {
+		records[i].Line, written[i].Line = 0, 0
+		assert.Equal(t, records[i], written[i])
+	}
+
+	assert.Error(t, w.WriteVersion())
+}
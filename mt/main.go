@@ -3,7 +3,9 @@ package mt
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
+	"github.com/rarimo/certificate-transparency-go/x509"
 	"github.com/rarimo/ldif-sdk/utils"
 	"gitlab.com/distributed_lab/logan/v3/errors"
 )
@@ -21,24 +23,23 @@ func newTreapTree() *TreapTree {
 // BuildTreeFromMarshalled builds a new dynamic Merkle tree with treap data structure
 // from raw pem certificates array marshalled in JSON,
 func BuildTreeFromMarshalled(elements []byte) (*TreapTree, error) {
-	treapTree := newTreapTree()
-
-	pemKeys := make([]string, 0)
-	if err := json.Unmarshal(elements, &pemKeys); err != nil {
-		return nil, errors.Wrap(err, "failed to unmarshal raw pem keys")
-	}
-
-	certificates, err := utils.ParsePemKeys(pemKeys)
+	certificates, err := parseMarshalled(elements)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed parse raw pem elements")
+		return nil, err
 	}
 
-	err = treapTree.mTree.BuildFromX509(certificates)
+	return BuildTreeFromX509(certificates)
+}
+
+// BuildTreeFromMarshalledAt builds a new dynamic Merkle tree like BuildTreeFromMarshalled,
+// taking only the certificates valid at the given unix timestamp in seconds
+func BuildTreeFromMarshalledAt(elements []byte, timestamp int64) (*TreapTree, error) {
+	certificates, err := parseMarshalled(elements)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to build tree")
+		return nil, err
 	}
 
-	return treapTree, nil
+	return BuildTreeFromX509(utils.ValidAt(certificates, time.Unix(timestamp, 0)))
 }
 
 // BuildTreeFromCollection builds a new dynamic Merkle tree with treap data structure
@@ -52,14 +53,32 @@ func BuildTreeFromMarshalled(elements []byte) (*TreapTree, error) {
 // ...
 // MIIDKzCCAtCgAwIBAgIII+3Lgsfb3yUwCgYIKoZIzj0EAwIweTEUMBIGA1UEAwwL
 func BuildTreeFromCollection(data []byte) (*TreapTree, error) {
-	treapTree := newTreapTree()
+	certificates, err := utils.ParseCertificatesCollection(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed parse raw pem elements")
+	}
 
+	return BuildTreeFromX509(certificates)
+}
+
+// BuildTreeFromCollectionAt builds a new dynamic Merkle tree like BuildTreeFromCollection,
+// taking only the certificates valid at the given unix timestamp in seconds
+func BuildTreeFromCollectionAt(data []byte, timestamp int64) (*TreapTree, error) {
 	certificates, err := utils.ParseCertificatesCollection(data)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed parse raw pem elements")
 	}
 
-	err = treapTree.mTree.BuildFromX509(certificates)
+	return BuildTreeFromX509(utils.ValidAt(certificates, time.Unix(timestamp, 0)))
+}
+
+// BuildTreeFromX509 builds a new dynamic Merkle tree with treap data structure
+// from parsed certificates, e.g. the ones filtered with LDIF.ValidAt. It is not
+// available in mobile bindings.
+func BuildTreeFromX509(certificates []*x509.Certificate) (*TreapTree, error) {
+	treapTree := newTreapTree()
+
+	err := treapTree.mTree.BuildFromX509(certificates)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build tree")
 	}
@@ -85,6 +104,21 @@ func BuildFromRaw(leaves []string) (*TreapTree, error) {
 	return treapTree, nil
 }
 
+// parseMarshalled parses raw pem certificates array marshalled in JSON
+func parseMarshalled(elements []byte) ([]*x509.Certificate, error) {
+	pemKeys := make([]string, 0)
+	if err := json.Unmarshal(elements, &pemKeys); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal raw pem keys")
+	}
+
+	certificates, err := utils.ParsePemKeys(pemKeys)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed parse raw pem elements")
+	}
+
+	return certificates, nil
+}
+
 // Root returns merkle tree root, if there is no tree empty string returned
 func (it *TreapTree) Root() []byte {
 	if it.mTree.tree == nil || it.mTree.tree.MerkleRoot() == nil {
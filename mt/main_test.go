@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/iden3/go-iden3-crypto/keccak256"
 	"github.com/rarimo/ldif-sdk/ldif"
@@ -80,7 +82,8 @@ func TestFromRawPKs(t *testing.T) {
 }
 
 func TestVerifyProof(t *testing.T) {
-	data, err := ldif.FromFile(ldifPath)
+	// canonical order keeps the tested certificate the same across ICAO releases
+	data, err := ldif.FromFile(ldifPath, ldif.WithSortOrder(ldif.SortBySPKI))
 	if err != nil {
 		t.Fatal(fmt.Errorf("reading LDIF file %w", err))
 	}
@@ -136,3 +139,67 @@ func buildRoot(input string, incProof Proof) (string, error) {
 
 	return fmt.Sprintf("0x%s", hex.EncodeToString(calculated)), nil
 }
+
+func TestBuildTreeAt(t *testing.T) {
+	data, err := os.ReadFile(masterListPath)
+	if err != nil {
+		t.Fatal(fmt.Errorf("reading pem file %w", err))
+	}
+
+	certificates, err := utils.ParseCertificatesCollection(data)
+	if err != nil {
+		t.Fatal(fmt.Errorf("parsing certificates %w", err))
+	}
+
+	// the instant when the newest certificate was issued, so the older ones may be expired
+	var now time.Time
+	for _, cert := range certificates {
+		if cert.NotBefore.After(now) {
+			now = cert.NotBefore
+		}
+	}
+
+	valid := utils.ValidAt(certificates, now)
+	if !assert.NotEmpty(t, valid) || !assert.Less(t, len(valid), len(certificates)) {
+		return
+	}
+
+	pems := make([]string, len(certificates))
+	for i, cert := range certificates {
+		pems[i] = string(pem.EncodeToMemory(&pem.Block{Type: utils.PemBlockType, Bytes: cert.Raw}))
+	}
+	rawCertificates, err := json.Marshal(pems)
+	if err != nil {
+		t.Fatal(fmt.Errorf("marshalling certificates %w", err))
+	}
+
+	validTree, err := BuildTreeFromX509(valid)
+	if err != nil {
+		t.Fatal(fmt.Errorf("building tree %w", err))
+	}
+
+	fullTree, err := BuildTreeFromCollection(data)
+	if err != nil {
+		t.Fatal(fmt.Errorf("building tree %w", err))
+	}
+	assert.NotEqual(t, fullTree.Root(), validTree.Root())
+
+	collectionTree, err := BuildTreeFromCollectionAt(data, now.Unix())
+	if err != nil {
+		t.Fatal(fmt.Errorf("building tree %w", err))
+	}
+	assert.Equal(t, validTree.Root(), collectionTree.Root())
+
+	marshalledTree, err := BuildTreeFromMarshalledAt(rawCertificates, now.Unix())
+	if err != nil {
+		t.Fatal(fmt.Errorf("building tree %w", err))
+	}
+	assert.Equal(t, validTree.Root(), marshalledTree.Root())
+
+	// nothing is valid in 2200
+	emptyTree, err := BuildTreeFromMarshalledAt(rawCertificates, 7258118400)
+	if err != nil {
+		t.Fatal(fmt.Errorf("building tree %w", err))
+	}
+	assert.Empty(t, emptyTree.Root())
+}
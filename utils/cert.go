@@ -5,6 +5,7 @@ import (
 	"crypto/rsa"
 	"fmt"
 	"math/big"
+	"time"
 
 	"github.com/rarimo/certificate-transparency-go/x509"
 )
@@ -39,3 +40,40 @@ func ExtractPubKeys(certs []*x509.Certificate) ([][]byte, error) {
 
 	return pubKeys, nil
 }
+
+// IsValidAt tells whether the instant is within the validity period of the
+// certificate, both bounds included
+func IsValidAt(cert *x509.Certificate, t time.Time) bool {
+	return !t.Before(cert.NotBefore) && !t.After(cert.NotAfter)
+}
+
+// ValidAt returns the certificates valid at the instant, e.g. the current time
+// or the signing time of a document. The result is empty, not nil, when none
+// of the certificates is valid.
+func ValidAt(certs []*x509.Certificate, t time.Time) []*x509.Certificate {
+	valid := make([]*x509.Certificate, 0, len(certs))
+	for _, cert := range certs {
+		if IsValidAt(cert, t) {
+			valid = append(valid, cert)
+		}
+	}
+
+	return valid
+}
+
+// ExpiringWithin returns the certificates valid at the instant that expire
+// within the window after it. The result is empty, not nil, when none of the
+// certificates expires.
+func ExpiringWithin(certs []*x509.Certificate, t time.Time, window time.Duration) []*x509.Certificate {
+	var (
+		deadline = t.Add(window)
+		expiring = make([]*x509.Certificate, 0)
+	)
+	for _, cert := range certs {
+		if IsValidAt(cert, t) && cert.NotAfter.Before(deadline) {
+			expiring = append(expiring, cert)
+		}
+	}
+
+	return expiring
+}